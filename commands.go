@@ -0,0 +1,433 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"text/template"
+
+	pgrpc "github.com/cble-platform/cble-provider-grpc/pkg/provider"
+	"github.com/cble-platform/provider-openstack/openstack"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// configureFromFile configures the provider using a local config file (for subcommands)
+func configureFromFile(provider *openstack.ProviderOpenstack, configFile string) error {
+	config, err := os.ReadFile(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+	reply, err := provider.Configure(context.Background(), &pgrpc.ConfigureRequest{
+		Config: config,
+	})
+	if err != nil {
+		return err
+	}
+	if !reply.Success {
+		return fmt.Errorf("failed to configure provider")
+	}
+	return nil
+}
+
+// readBlueprintResources renders a blueprint file with a vars file (if set) and splits it into
+// resources the same way CBLE does before calling the provider
+func readBlueprintResources(blueprintFile string, varsFile string) ([]*pgrpc.Resource, error) {
+	blueprint, err := os.ReadFile(blueprintFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blueprint file: %v", err)
+	}
+
+	// Render the blueprint template vars
+	vars := map[string]any{}
+	if varsFile != "" {
+		varsBytes, err := os.ReadFile(varsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vars file: %v", err)
+		}
+		if err := yaml.Unmarshal(varsBytes, &vars); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal vars: %v", err)
+		}
+	}
+	blueprintTemplate, err := template.New("blueprint").Parse(string(blueprint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse blueprint template: %v", err)
+	}
+	var rendered bytes.Buffer
+	if err := blueprintTemplate.Execute(&rendered, vars); err != nil {
+		return nil, fmt.Errorf("failed to render blueprint: %v", err)
+	}
+
+	// Split the blueprint into one resource per object
+	var root yaml.Node
+	if err := yaml.Unmarshal(rendered.Bytes(), &root); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal blueprint: %v", err)
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("blueprint must be a map of objects")
+	}
+
+	// Parse the whole blueprint first so problems are located within the blueprint file
+	var parsed openstack.OpenstackBlueprint
+	if err := root.Content[0].Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse blueprint: %v", err)
+	}
+	resources := []*pgrpc.Resource{}
+	objects := root.Content[0].Content
+	for i := 0; i+1 < len(objects); i += 2 {
+		// Skip non-object values (e.g. version)
+		if objects[i+1].Kind != yaml.MappingNode {
+			continue
+		}
+		object, err := yaml.Marshal(objects[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal object %s: %v", objects[i].Value, err)
+		}
+		resources = append(resources, &pgrpc.Resource{
+			Id:     objects[i].Value,
+			Key:    objects[i].Value,
+			Object: object,
+		})
+	}
+	return resources, nil
+}
+
+// sweepCommand finds (and optionally deletes) cloud resources leaked by unknown deployments
+func sweepCommand(args []string) int {
+	flags := flag.NewFlagSet("sweep", flag.ExitOnError)
+	configFile := flags.String("config", "config.yaml", "path to the provider config file")
+	knownDeploymentsFile := flags.String("known-deployments", "", "path to a file containing the IDs of all known CBLE deployments (one per line)")
+	deleteOrphans := flags.Bool("delete", false, "delete orphaned resources (otherwise only report them)")
+	minAge := flags.Duration("min-age", openstack.DefaultSweepMinAge, "minimum age of a resource before it is considered orphaned")
+	matchNamePrefix := flags.Bool("match-name-prefix", false, "also match untagged resources by the default name prefix")
+	debug := flags.Bool("debug", false, "enable debug logging")
+	flags.Parse(args)
+
+	if *debug {
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+
+	// Refuse to sweep without known deployments (everything would be an orphan)
+	if *knownDeploymentsFile == "" {
+		logrus.Errorf("-known-deployments is required")
+		return 1
+	}
+	knownDeployments, err := openstack.ReadKnownDeployments(*knownDeploymentsFile)
+	if err != nil {
+		logrus.Errorf("%v", err)
+		return 1
+	}
+
+	provider := openstack.ProviderOpenstack{}
+	if err := configureFromFile(&provider, *configFile); err != nil {
+		logrus.Errorf("failed to configure provider: %v", err)
+		return 1
+	}
+
+	report, err := provider.Sweep(openstack.SweepOptions{
+		KnownDeployments: knownDeployments,
+		Delete:           *deleteOrphans,
+		MinAge:           *minAge,
+		MatchNamePrefix:  *matchNamePrefix,
+	})
+	if err != nil {
+		logrus.Errorf("failed to sweep: %v", err)
+		return 1
+	}
+
+	// Print the report
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tID\tNAME\tDEPLOYMENT\tDELETED\tERROR")
+	failed := false
+	for _, orphan := range report.Orphans {
+		errString := ""
+		if orphan.Error != nil {
+			errString = orphan.Error.Error()
+			failed = true
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%s\n", orphan.Type, orphan.ID, orphan.Name, orphan.DeploymentID, orphan.Deleted, errString)
+	}
+	w.Flush()
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// destroyNetworkCommand removes a network and everything using it (router interfaces and stray ports)
+func destroyNetworkCommand(args []string) int {
+	flags := flag.NewFlagSet("destroy-network", flag.ExitOnError)
+	configFile := flags.String("config", "config.yaml", "path to the provider config file")
+	networkId := flags.String("id", "", "the Openstack ID of the network to destroy")
+	dryRun := flags.Bool("dry-run", false, "only list what would be removed")
+	deleteAttachedPorts := flags.Bool("delete-attached-ports", false, "also delete ports attached to other devices (e.g. running servers)")
+	debug := flags.Bool("debug", false, "enable debug logging")
+	flags.Parse(args)
+
+	if *debug {
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+
+	if *networkId == "" {
+		logrus.Errorf("-id is required")
+		return 1
+	}
+
+	provider := openstack.ProviderOpenstack{}
+	if err := configureFromFile(&provider, *configFile); err != nil {
+		logrus.Errorf("failed to configure provider: %v", err)
+		return 1
+	}
+
+	steps, err := provider.CascadeDestroyNetwork(*networkId, *dryRun, *deleteAttachedPorts)
+
+	// Print the steps (even if they failed part way through)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ACTION\tID\tNAME\tDEVICE OWNER\tDEVICE\tROUTER")
+	for _, step := range steps {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", step.Action, step.ID, step.Name, step.DeviceOwner, step.DeviceID, step.RouterID)
+	}
+	w.Flush()
+
+	if err != nil {
+		logrus.Errorf("failed to destroy network: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// checkQuotaCommand checks a blueprint fits in the project quota before deploying it
+func checkQuotaCommand(args []string) int {
+	flags := flag.NewFlagSet("check-quota", flag.ExitOnError)
+	configFile := flags.String("config", "config.yaml", "path to the provider config file")
+	blueprintFile := flags.String("blueprint", "", "path to the blueprint file")
+	varsFile := flags.String("vars", "", "path to a YAML file of blueprint template vars")
+	debug := flags.Bool("debug", false, "enable debug logging")
+	flags.Parse(args)
+
+	if *debug {
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+
+	if *blueprintFile == "" {
+		logrus.Errorf("-blueprint is required")
+		return 1
+	}
+	resources, err := readBlueprintResources(*blueprintFile, *varsFile)
+	if err != nil {
+		logrus.Errorf("%v", err)
+		return 1
+	}
+
+	provider := openstack.ProviderOpenstack{}
+	if err := configureFromFile(&provider, *configFile); err != nil {
+		logrus.Errorf("failed to configure provider: %v", err)
+		return 1
+	}
+
+	report, err := provider.CheckQuota(resources)
+	if err != nil {
+		logrus.Errorf("failed to check quota: %v", err)
+		return 1
+	}
+
+	// Print the report
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVICE\tQUOTA\tLIMIT\tIN USE\tREQUIRED\tSHORTFALL")
+	for _, item := range report.Items {
+		limit := fmt.Sprint(item.Limit)
+		if item.Limit < 0 {
+			limit = "unlimited"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\n", item.Service, item.Quota, limit, item.InUse, item.Required, item.Shortfall())
+	}
+	w.Flush()
+
+	if err := report.Err(); err != nil {
+		logrus.Errorf("%v", err)
+		return 1
+	}
+	return 0
+}
+
+// validateCommand validates a blueprint, including checking its references against the live catalog
+func validateCommand(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	configFile := flags.String("config", "config.yaml", "path to the provider config file")
+	blueprintFile := flags.String("blueprint", "", "path to the blueprint file")
+	varsFile := flags.String("vars", "", "path to a YAML file of blueprint template vars")
+	debug := flags.Bool("debug", false, "enable debug logging")
+	flags.Parse(args)
+
+	if *debug {
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+
+	if *blueprintFile == "" {
+		logrus.Errorf("-blueprint is required")
+		return 1
+	}
+	resources, err := readBlueprintResources(*blueprintFile, *varsFile)
+	if err != nil {
+		logrus.Errorf("%v", err)
+		return 1
+	}
+
+	provider := openstack.ProviderOpenstack{}
+	if err := configureFromFile(&provider, *configFile); err != nil {
+		logrus.Errorf("failed to configure provider: %v", err)
+		return 1
+	}
+
+	if err := provider.ValidateResources(resources); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	fmt.Println("blueprint is valid")
+	return 0
+}
+
+// schemaCommand prints the JSON schema of a blueprint (or of the config of a single object type)
+func schemaCommand(args []string) int {
+	flags := flag.NewFlagSet("schema", flag.ExitOnError)
+	objectType := flags.String("type", "", "only print the config schema of this object type (e.g. openstack.v1.host)")
+	forData := flags.Bool("data", false, "print the config schema of the type when used as data")
+	flags.Parse(args)
+
+	var schema map[string]any
+	if *objectType == "" {
+		schema = openstack.BlueprintSchema()
+	} else {
+		var err error
+		if schema, err = openstack.ConfigSchema(openstack.OpenstackResourceType(*objectType), *forData); err != nil {
+			logrus.Errorf("%v", err)
+			return 1
+		}
+	}
+
+	out, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		logrus.Errorf("failed to marshal schema: %v", err)
+		return 1
+	}
+	fmt.Println(string(out))
+	return 0
+}
+
+// refreshCommand reports how deployed resources drifted from their saved vars (and optionally
+// updates the vars to match)
+func refreshCommand(args []string) int {
+	flags := flag.NewFlagSet("refresh", flag.ExitOnError)
+	configFile := flags.String("config", "config.yaml", "path to the provider config file")
+	stateFile := flags.String("state", "", "path to a YAML file of the vars saved for each resource (by resource key)")
+	blueprintFile := flags.String("blueprint", "", "path to the blueprint file (defaults to the deployed objects saved in the state)")
+	varsFile := flags.String("vars", "", "path to a YAML file of blueprint template vars")
+	update := flags.Bool("update", false, "write the refreshed vars back to the state file")
+	jsonOutput := flags.Bool("json", false, "print the drift reports as JSON")
+	debug := flags.Bool("debug", false, "enable debug logging")
+	flags.Parse(args)
+
+	if *debug {
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+
+	if *stateFile == "" {
+		logrus.Errorf("-state is required")
+		return 1
+	}
+	stateBytes, err := os.ReadFile(*stateFile)
+	if err != nil {
+		logrus.Errorf("failed to read state file: %v", err)
+		return 1
+	}
+	state := map[string]map[string]string{}
+	if err := yaml.Unmarshal(stateBytes, &state); err != nil {
+		logrus.Errorf("failed to unmarshal state: %v", err)
+		return 1
+	}
+
+	// Refresh the blueprint resources, or the objects the resources were deployed from (if no blueprint)
+	var resources []*pgrpc.Resource
+	if *blueprintFile != "" {
+		resources, err = readBlueprintResources(*blueprintFile, *varsFile)
+		if err != nil {
+			logrus.Errorf("%v", err)
+			return 1
+		}
+	}
+
+	provider := openstack.ProviderOpenstack{}
+	if err := configureFromFile(&provider, *configFile); err != nil {
+		logrus.Errorf("failed to configure provider: %v", err)
+		return 1
+	}
+
+	reports, err := provider.RefreshResources(context.Background(), resources, state)
+	if err != nil {
+		logrus.Errorf("failed to refresh: %v", err)
+		return 1
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Key < reports[j].Key
+	})
+
+	// Print the reports
+	failed := false
+	drifted := false
+	for _, report := range reports {
+		failed = failed || report.Error != ""
+		drifted = drifted || report.Drifted()
+	}
+	if *jsonOutput {
+		out, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			logrus.Errorf("failed to marshal reports: %v", err)
+			return 1
+		}
+		fmt.Println(string(out))
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tTYPE\tDRIFT\tVAR\tSAVED\tCURRENT\tERROR")
+		for _, report := range reports {
+			if report.Error != "" {
+				fmt.Fprintf(w, "%s\t%s\t\t\t\t\t%s\n", report.Key, report.Type, report.Error)
+			}
+			for _, drift := range report.Drift {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n", report.Key, report.Type, drift.Kind, drift.Var, drift.Saved, drift.Current)
+			}
+		}
+		w.Flush()
+	}
+
+	// Save the refreshed vars of every resource which refreshed successfully
+	if *update && drifted {
+		for _, report := range reports {
+			if report.Error == "" {
+				state[report.Key] = report.Vars
+			}
+		}
+		out, err := yaml.Marshal(state)
+		if err != nil {
+			logrus.Errorf("failed to marshal state: %v", err)
+			return 1
+		}
+		if err := os.WriteFile(*stateFile, out, 0644); err != nil {
+			logrus.Errorf("failed to write state file: %v", err)
+			return 1
+		}
+	}
+
+	if failed {
+		return 1
+	}
+	if drifted {
+		return 2
+	}
+	return 0
+}
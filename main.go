@@ -14,6 +14,24 @@ import (
 func main() {
 	// TODO: Add CLI flags to allow non-default CBLE connect (e.g. TLS)
 
+	// Check for provider subcommands
+	if len(os.Args) >= 2 {
+		switch os.Args[1] {
+		case "sweep":
+			os.Exit(sweepCommand(os.Args[2:]))
+		case "destroy-network":
+			os.Exit(destroyNetworkCommand(os.Args[2:]))
+		case "check-quota":
+			os.Exit(checkQuotaCommand(os.Args[2:]))
+		case "validate":
+			os.Exit(validateCommand(os.Args[2:]))
+		case "schema":
+			os.Exit(schemaCommand(os.Args[2:]))
+		case "refresh":
+			os.Exit(refreshCommand(os.Args[2:]))
+		}
+	}
+
 	// Check if the ID is passed in via command line
 	if len(os.Args) < 2 {
 		logrus.Errorf("no ID passed to provider")
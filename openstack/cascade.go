@@ -0,0 +1,222 @@
+package openstack
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/routers"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/subnets"
+	"github.com/gophercloud/gophercloud/pagination"
+	"github.com/sirupsen/logrus"
+)
+
+type NetworkCascadeAction string
+
+// Actions in the order they are run
+const (
+	NetworkCascadeActionRemoveRouterInterface NetworkCascadeAction = "remove_router_interface"
+	NetworkCascadeActionClearRouterGateway    NetworkCascadeAction = "clear_router_gateway"
+	NetworkCascadeActionDeletePort            NetworkCascadeAction = "delete_port"
+	NetworkCascadeActionSkipPort              NetworkCascadeAction = "skip_port"
+	NetworkCascadeActionBlockedPort           NetworkCascadeAction = "blocked_port"
+	NetworkCascadeActionDeleteSubnet          NetworkCascadeAction = "delete_subnet"
+	NetworkCascadeActionDeleteNetwork         NetworkCascadeAction = "delete_network"
+)
+
+type NetworkCascadeStep struct {
+	// The action to take
+	Action NetworkCascadeAction
+	// The ID of the Openstack object the action is taken on
+	ID string
+	// The name of the Openstack object the action is taken on
+	Name string
+	// The device owner of the port (only for port actions)
+	DeviceOwner string
+	// The device the port is attached to (only for port actions)
+	DeviceID string
+	// The router of the interface or gateway (only for router actions)
+	RouterID string
+}
+
+// CascadeDestroyNetwork removes everything using a network (router interfaces, router gateways and
+// ports) and then deletes its subnets and the network. Ports attached to other devices (e.g. the ports
+// of running servers) block the cascade unless deleteAttachedPorts is set. If dryRun is set, only the
+// steps are returned
+func (provider ProviderOpenstack) CascadeDestroyNetwork(networkId string, dryRun bool, deleteAttachedPorts bool) ([]NetworkCascadeStep, error) {
+	// Check if the provider has been configured
+	if CONFIG == nil {
+		return nil, fmt.Errorf("cannot destroy with unconfigured provider, please call Configure()")
+	}
+
+	// Generate authenticated client session
+	authClient, err := provider.newAuthClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to authenticate: %v", err)
+	}
+
+	// Generate the Network V2 client
+	networkClient, err := openstack.NewNetworkV2(authClient, gophercloud.EndpointOpts{
+		Name:   "neutron",
+		Region: CONFIG.RegionName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create openstack network client: %v", err)
+	}
+
+	steps, err := planNetworkCascade(networkClient, networkId, deleteAttachedPorts)
+	if err != nil {
+		return nil, err
+	}
+	if dryRun {
+		return steps, nil
+	}
+	return steps, runNetworkCascade(networkClient, steps)
+}
+
+// planNetworkCascade lists the steps needed to remove a network and everything using it. Ports
+// attached to other devices are planned as blocked unless deleteAttachedPorts is set
+func planNetworkCascade(networkClient *gophercloud.ServiceClient, networkId string, deleteAttachedPorts bool) ([]NetworkCascadeStep, error) {
+	network, err := networks.Get(networkClient, networkId).Extract()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get network: %v", err)
+	}
+
+	// Get all ports on the network
+	networkPorts := []ports.Port{}
+	err = ports.List(networkClient, ports.ListOpts{
+		NetworkID: networkId,
+	}).EachPage(func(p pagination.Page) (bool, error) {
+		pp, err := ports.ExtractPorts(p)
+		if err != nil {
+			return false, fmt.Errorf("failed to extract port pages")
+		}
+		networkPorts = append(networkPorts, pp...)
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network ports: %v", err)
+	}
+
+	// Get all subnets on the network
+	networkSubnets := []subnets.Subnet{}
+	err = subnets.List(networkClient, subnets.ListOpts{
+		NetworkID: networkId,
+	}).EachPage(func(p pagination.Page) (bool, error) {
+		ss, err := subnets.ExtractSubnets(p)
+		if err != nil {
+			return false, fmt.Errorf("failed to extract subnet pages")
+		}
+		networkSubnets = append(networkSubnets, ss...)
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network subnets: %v", err)
+	}
+
+	steps := cascadePortSteps(networkPorts, deleteAttachedPorts)
+	for _, subnet := range networkSubnets {
+		steps = append(steps, NetworkCascadeStep{
+			Action: NetworkCascadeActionDeleteSubnet,
+			ID:     subnet.ID,
+			Name:   subnet.Name,
+		})
+	}
+	steps = append(steps, NetworkCascadeStep{
+		Action: NetworkCascadeActionDeleteNetwork,
+		ID:     network.ID,
+		Name:   network.Name,
+	})
+
+	return steps, nil
+}
+
+// cascadePortSteps plans the removal of the ports on a network. Router ports must be removed through
+// the router before any other ports
+func cascadePortSteps(networkPorts []ports.Port, deleteAttachedPorts bool) []NetworkCascadeStep {
+	routerSteps := []NetworkCascadeStep{}
+	portSteps := []NetworkCascadeStep{}
+	for _, port := range networkPorts {
+		step := NetworkCascadeStep{
+			ID:          port.ID,
+			Name:        port.Name,
+			DeviceOwner: port.DeviceOwner,
+			DeviceID:    port.DeviceID,
+		}
+		switch {
+		case isRouterInterfacePort(port.DeviceOwner):
+			step.Action = NetworkCascadeActionRemoveRouterInterface
+			step.RouterID = port.DeviceID
+			routerSteps = append(routerSteps, step)
+		case port.DeviceOwner == "network:router_gateway":
+			step.Action = NetworkCascadeActionClearRouterGateway
+			step.RouterID = port.DeviceID
+			routerSteps = append(routerSteps, step)
+		case strings.HasPrefix(port.DeviceOwner, "network:"):
+			// DHCP, floating IP, etc. ports are owned by Neutron and removed along with the subnet
+			step.Action = NetworkCascadeActionSkipPort
+			portSteps = append(portSteps, step)
+		case port.DeviceID != "" && !deleteAttachedPorts:
+			// Deleting the ports of other devices (e.g. running servers) would silently break them
+			step.Action = NetworkCascadeActionBlockedPort
+			portSteps = append(portSteps, step)
+		default:
+			step.Action = NetworkCascadeActionDeletePort
+			portSteps = append(portSteps, step)
+		}
+	}
+	return append(routerSteps, portSteps...)
+}
+
+// runNetworkCascade runs the steps from planNetworkCascade in order (nothing is run if any step is blocked)
+func runNetworkCascade(networkClient *gophercloud.ServiceClient, steps []NetworkCascadeStep) error {
+	for _, step := range steps {
+		if step.Action == NetworkCascadeActionBlockedPort {
+			return fmt.Errorf("port %s is attached to %s device %s (remove the device first or delete attached ports explicitly)", step.ID, step.DeviceOwner, step.DeviceID)
+		}
+	}
+
+	for _, step := range steps {
+		logrus.Debugf("Cascade %s %s (%s)", step.Action, step.Name, step.ID)
+
+		var err error
+		switch step.Action {
+		case NetworkCascadeActionRemoveRouterInterface:
+			_, err = routers.RemoveInterface(networkClient, step.RouterID, routers.RemoveInterfaceOpts{
+				PortID: step.ID,
+			}).Extract()
+		case NetworkCascadeActionClearRouterGateway:
+			_, err = routers.Update(networkClient, step.RouterID, routers.UpdateOpts{
+				GatewayInfo: &routers.GatewayInfo{},
+			}).Extract()
+		case NetworkCascadeActionDeletePort:
+			err = ports.Delete(networkClient, step.ID).ExtractErr()
+		case NetworkCascadeActionSkipPort:
+			continue
+		case NetworkCascadeActionDeleteSubnet:
+			if err = subnets.Delete(networkClient, step.ID).ExtractErr(); err == nil {
+				// Wait for the subnet to be fully deleted
+				err = waitForDeletion(func() error {
+					_, err := subnets.Get(networkClient, step.ID).Extract()
+					return err
+				})
+			}
+		case NetworkCascadeActionDeleteNetwork:
+			if err = networks.Delete(networkClient, step.ID).ExtractErr(); err == nil {
+				// Wait for the network to be fully deleted
+				err = waitForDeletion(func() error {
+					_, err := networks.Get(networkClient, step.ID).Extract()
+					return err
+				})
+			}
+		}
+		// Objects may already be gone (e.g. ports removed along with a router interface)
+		if err != nil && !isNotFound(err) {
+			return fmt.Errorf("failed to %s %s: %v", strings.ReplaceAll(string(step.Action), "_", " "), step.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package openstack
+
+import (
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+)
+
+func TestCascadePortSteps(t *testing.T) {
+	networkPorts := []ports.Port{
+		{ID: "server", DeviceOwner: "compute:nova", DeviceID: "server-id"},
+		{ID: "stray", DeviceOwner: ""},
+		{ID: "dhcp", DeviceOwner: "network:dhcp", DeviceID: "dhcp-id"},
+		{ID: "interface", DeviceOwner: "network:router_interface", DeviceID: "router-a"},
+		{ID: "ha-interface", DeviceOwner: "network:ha_router_replicated_interface", DeviceID: "router-b"},
+		{ID: "dvr-interface", DeviceOwner: "network:router_interface_distributed", DeviceID: "router-c"},
+		{ID: "gateway", DeviceOwner: "network:router_gateway", DeviceID: "router-d"},
+	}
+
+	tests := []struct {
+		name                string
+		deleteAttachedPorts bool
+		want                map[string]NetworkCascadeAction
+	}{
+		{
+			name: "attached ports blocked",
+			want: map[string]NetworkCascadeAction{
+				"interface":     NetworkCascadeActionRemoveRouterInterface,
+				"ha-interface":  NetworkCascadeActionRemoveRouterInterface,
+				"dvr-interface": NetworkCascadeActionRemoveRouterInterface,
+				"gateway":       NetworkCascadeActionClearRouterGateway,
+				"server":        NetworkCascadeActionBlockedPort,
+				"stray":         NetworkCascadeActionDeletePort,
+				"dhcp":          NetworkCascadeActionSkipPort,
+			},
+		},
+		{
+			name:                "attached ports deleted",
+			deleteAttachedPorts: true,
+			want: map[string]NetworkCascadeAction{
+				"interface":     NetworkCascadeActionRemoveRouterInterface,
+				"ha-interface":  NetworkCascadeActionRemoveRouterInterface,
+				"dvr-interface": NetworkCascadeActionRemoveRouterInterface,
+				"gateway":       NetworkCascadeActionClearRouterGateway,
+				"server":        NetworkCascadeActionDeletePort,
+				"stray":         NetworkCascadeActionDeletePort,
+				"dhcp":          NetworkCascadeActionSkipPort,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps := cascadePortSteps(networkPorts, tt.deleteAttachedPorts)
+			if len(steps) != len(tt.want) {
+				t.Fatalf("got %d steps, want %d", len(steps), len(tt.want))
+			}
+			routerStepsDone := false
+			for _, step := range steps {
+				if step.Action != tt.want[step.ID] {
+					t.Errorf("port %s: got %s, want %s", step.ID, step.Action, tt.want[step.ID])
+				}
+				// Router steps must come before every other port
+				isRouterStep := step.Action == NetworkCascadeActionRemoveRouterInterface || step.Action == NetworkCascadeActionClearRouterGateway
+				if !isRouterStep {
+					routerStepsDone = true
+				} else if routerStepsDone {
+					t.Errorf("router step for %s is planned after other ports", step.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestRunNetworkCascadeBlocked(t *testing.T) {
+	// Nothing is run (so no client is needed) when a step is blocked
+	err := runNetworkCascade(nil, []NetworkCascadeStep{
+		{Action: NetworkCascadeActionDeletePort, ID: "stray"},
+		{Action: NetworkCascadeActionBlockedPort, ID: "server", DeviceOwner: "compute:nova", DeviceID: "server-id"},
+	})
+	if err == nil {
+		t.Fatal("expected the blocked port to fail the cascade")
+	}
+}
@@ -0,0 +1,752 @@
+package openstack
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	pgrpc "github.com/cble-platform/cble-provider-grpc/pkg/provider"
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/availabilityzones"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+	"github.com/gophercloud/gophercloud/openstack/imageservice/v2/images"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+	"github.com/gophercloud/gophercloud/pagination"
+	"github.com/sirupsen/logrus"
+)
+
+// The default time flavors and images are cached for
+const defaultCatalogCacheTTL = 5 * time.Minute
+
+// catalogCache caches the flavor and image lists (shared by all RPCs) so large catalogs
+// aren't listed once per host
+type catalogCache struct {
+	lock sync.Mutex
+
+	flavorsByID   map[string]*flavors.Flavor
+	flavorsByName map[string][]*flavors.Flavor
+	flavorList    []flavors.Flavor
+	flavorsExpire time.Time
+
+	imagesByID   map[string]*images.Image
+	imagesByName map[string][]*images.Image
+	imageList    []images.Image
+	imagesExpire time.Time
+}
+
+// The catalog cache (reset whenever the provider is configured)
+var CATALOG = &catalogCache{}
+
+// reset invalidates all cached flavors and images
+func (c *catalogCache) reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.flavorsByID, c.flavorsByName, c.flavorList = nil, nil, nil
+	c.flavorsExpire = time.Time{}
+	c.imagesByID, c.imagesByName, c.imageList = nil, nil, nil
+	c.imagesExpire = time.Time{}
+}
+
+// catalogCacheTTL returns the configured catalog cache TTL (or the default if not set)
+func catalogCacheTTL() time.Duration {
+	if CONFIG != nil && CONFIG.CatalogCacheTTL != 0 {
+		return CONFIG.CatalogCacheTTL
+	}
+	return defaultCatalogCacheTTL
+}
+
+// refreshFlavors lists all flavors if the cache has expired. Must be called with the lock held
+func (c *catalogCache) refreshFlavors(computeClient *gophercloud.ServiceClient) error {
+	if c.flavorList != nil && time.Now().Before(c.flavorsExpire) {
+		return nil
+	}
+	logrus.Debugf("Refreshing flavor cache")
+
+	allFlavorPages, err := flavors.ListDetail(computeClient, nil).AllPages()
+	if err != nil {
+		return fmt.Errorf("failed to list flavors: %v", err)
+	}
+	allFlavors, err := flavors.ExtractFlavors(allFlavorPages)
+	if err != nil {
+		return fmt.Errorf("failed to list flavors: %v", err)
+	}
+
+	c.flavorList = allFlavors
+	c.flavorsByID = make(map[string]*flavors.Flavor)
+	c.flavorsByName = make(map[string][]*flavors.Flavor)
+	for i := range c.flavorList {
+		fl := &c.flavorList[i]
+		c.flavorsByID[fl.ID] = fl
+		c.flavorsByName[fl.Name] = append(c.flavorsByName[fl.Name], fl)
+	}
+	c.flavorsExpire = time.Now().Add(catalogCacheTTL())
+	return nil
+}
+
+// refreshImages lists all images from Glance if the cache has expired. Must be called with the lock held
+func (c *catalogCache) refreshImages(imageClient *gophercloud.ServiceClient) error {
+	if c.imageList != nil && time.Now().Before(c.imagesExpire) {
+		return nil
+	}
+	logrus.Debugf("Refreshing image cache")
+
+	allImagePages, err := images.List(imageClient, images.ListOpts{}).AllPages()
+	if err != nil {
+		return fmt.Errorf("failed to list images: %v", err)
+	}
+	allImages, err := images.ExtractImages(allImagePages)
+	if err != nil {
+		return fmt.Errorf("failed to list images: %v", err)
+	}
+
+	c.imageList = allImages
+	c.imagesByID = make(map[string]*images.Image)
+	c.imagesByName = make(map[string][]*images.Image)
+	for i := range c.imageList {
+		img := &c.imageList[i]
+		c.imagesByID[img.ID] = img
+		// Only active images can be used by name
+		if img.Status == images.ImageStatusActive {
+			c.imagesByName[img.Name] = append(c.imagesByName[img.Name], img)
+		}
+	}
+	c.imagesExpire = time.Now().Add(catalogCacheTTL())
+	return nil
+}
+
+// Flavors returns all (cached) flavors
+func (c *catalogCache) Flavors(computeClient *gophercloud.ServiceClient) ([]flavors.Flavor, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.refreshFlavors(computeClient); err != nil {
+		return nil, err
+	}
+	return c.flavorList, nil
+}
+
+// Flavor returns the (cached) flavor with the given ID or name
+func (c *catalogCache) Flavor(computeClient *gophercloud.ServiceClient, nameOrId string) (*flavors.Flavor, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.refreshFlavors(computeClient); err != nil {
+		return nil, err
+	}
+	if fl, ok := c.flavorsByID[nameOrId]; ok {
+		return fl, nil
+	}
+	matches := c.flavorsByName[nameOrId]
+	if len(matches) == 0 {
+		// The flavor may have been created since the cache was refreshed, so look it up directly
+		var err error
+		if matches, err = findFlavor(computeClient, nameOrId); err != nil {
+			return nil, err
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("flavor not found")
+	case 1:
+		return matches[0], nil
+	default:
+		candidates := make([]string, len(matches))
+		for i, fl := range matches {
+			candidates[i] = fmt.Sprintf("%s (%s)", fl.Name, fl.ID)
+		}
+		return nil, ambiguousMatchError("flavor", candidates)
+	}
+}
+
+// Image returns the (cached) image with the given ID or name
+func (c *catalogCache) Image(imageClient *gophercloud.ServiceClient, nameOrId string) (*images.Image, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.refreshImages(imageClient); err != nil {
+		return nil, err
+	}
+	if img, ok := c.imagesByID[nameOrId]; ok {
+		return img, nil
+	}
+	matches := c.imagesByName[nameOrId]
+	if len(matches) == 0 {
+		// The image may have been created since the cache was refreshed, so look it up directly
+		var err error
+		if matches, err = findImage(imageClient, nameOrId); err != nil {
+			return nil, err
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("image not found")
+	case 1:
+		return matches[0], nil
+	default:
+		candidates := make([]string, len(matches))
+		for i, img := range matches {
+			candidates[i] = fmt.Sprintf("%s (%s)", img.Name, img.ID)
+		}
+		return nil, ambiguousMatchError("image", candidates)
+	}
+}
+
+// findFlavor gets a flavor missing from the cache from Nova, by ID and then by name
+func findFlavor(computeClient *gophercloud.ServiceClient, nameOrId string) ([]*flavors.Flavor, error) {
+	fl, err := flavors.Get(computeClient, nameOrId).Extract()
+	if err == nil {
+		return []*flavors.Flavor{fl}, nil
+	}
+	if !isNotFound(err) {
+		return nil, fmt.Errorf("failed to get flavor: %v", err)
+	}
+
+	matches := []*flavors.Flavor{}
+	err = flavors.ListDetail(computeClient, nil).EachPage(func(p pagination.Page) (bool, error) {
+		ff, err := flavors.ExtractFlavors(p)
+		if err != nil {
+			return false, fmt.Errorf("failed to extract flavor pages")
+		}
+		for i := range ff {
+			if ff[i].Name == nameOrId {
+				matches = append(matches, &ff[i])
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list flavors: %v", err)
+	}
+	return matches, nil
+}
+
+// findImage gets an image missing from the cache from Glance, by ID and then by name (only active
+// images can be used by name)
+func findImage(imageClient *gophercloud.ServiceClient, nameOrId string) ([]*images.Image, error) {
+	img, err := images.Get(imageClient, nameOrId).Extract()
+	if err == nil {
+		return []*images.Image{img}, nil
+	}
+	if !isNotFound(err) {
+		return nil, fmt.Errorf("failed to get image: %v", err)
+	}
+
+	matches := []*images.Image{}
+	err = images.List(imageClient, images.ListOpts{
+		Name:   nameOrId,
+		Status: images.ImageStatusActive,
+	}).EachPage(func(p pagination.Page) (bool, error) {
+		ii, err := images.ExtractImages(p)
+		if err != nil {
+			return false, fmt.Errorf("failed to extract image pages")
+		}
+		for i := range ii {
+			matches = append(matches, &ii[i])
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %v", err)
+	}
+	return matches, nil
+}
+
+// Comparators for each image sort key
+var imageSortKeys = map[string]func(a, b *images.Image) bool{
+	"name":       func(a, b *images.Image) bool { return a.Name < b.Name },
+	"created_at": func(a, b *images.Image) bool { return a.CreatedAt.Before(b.CreatedAt) },
+	"updated_at": func(a, b *images.Image) bool { return a.UpdatedAt.Before(b.UpdatedAt) },
+	"size":       func(a, b *images.Image) bool { return a.SizeBytes < b.SizeBytes },
+	"min_disk":   func(a, b *images.Image) bool { return a.MinDiskGigabytes < b.MinDiskGigabytes },
+	"min_ram":    func(a, b *images.Image) bool { return a.MinRAMMegabytes < b.MinRAMMegabytes },
+}
+
+// Comparators for each flavor sort key
+var flavorSortKeys = map[string]func(a, b *flavors.Flavor) bool{
+	"name":  func(a, b *flavors.Flavor) bool { return a.Name < b.Name },
+	"vcpus": func(a, b *flavors.Flavor) bool { return a.VCPUs < b.VCPUs },
+	"ram":   func(a, b *flavors.Flavor) bool { return a.RAM < b.RAM },
+	"disk":  func(a, b *flavors.Flavor) bool { return a.Disk < b.Disk },
+}
+
+// dependencyVar gets a var of the dependency with the given key (if the key is a dependency)
+func dependencyVar(dependencyVars map[string]*pgrpc.DependencyVars, key string, varName string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	depVars, ok := dependencyVars[key]
+	if !ok || depVars == nil {
+		return "", false
+	}
+	value, exists := depVars.Vars[varName]
+	return value, exists
+}
+
+// validateSortDir checks the sort direction is valid and returns whether it is descending
+func validateSortDir(sortDir string) (bool, error) {
+	switch sortDir {
+	case "", "asc":
+		return false, nil
+	case "desc":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown sort_dir \"%s\" (must be asc or desc)", sortDir)
+	}
+}
+
+// compileNameRegex compiles the optional name regex of a selector
+func compileNameRegex(nameRegex *string) (*regexp.Regexp, error) {
+	if nameRegex == nil {
+		return nil, nil
+	}
+	re, err := regexp.Compile(*nameRegex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name_regex: %v", err)
+	}
+	return re, nil
+}
+
+// selectImage finds the Glance image matching the selector. If more than one image matches, the
+// first after sorting is selected (or an error is returned if no sort key is set)
+func selectImage(imageClient *gophercloud.ServiceClient, selector *OpenstackImage) (*images.Image, error) {
+	// If ID is present, just get image by id
+	if selector.ID != nil {
+		image, err := images.Get(imageClient, *selector.ID).Extract()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get image by ID: %v", err)
+		}
+		return image, nil
+	}
+
+	nameRegex, err := compileNameRegex(selector.NameRegex)
+	if err != nil {
+		return nil, err
+	}
+	less, sortable := imageSortKeys[selector.SortKey]
+	if selector.SortKey != "" && !sortable {
+		return nil, fmt.Errorf("unknown sort_key \"%s\"", selector.SortKey)
+	}
+	descending, err := validateSortDir(selector.SortDir)
+	if err != nil {
+		return nil, err
+	}
+
+	listOpts := images.ListOpts{
+		Tags:       selector.Tags,
+		Visibility: images.ImageVisibility(selector.Visibility),
+		Status:     images.ImageStatusActive,
+	}
+	if selector.Name != nil {
+		listOpts.Name = *selector.Name
+	}
+	if selector.Status != "" {
+		listOpts.Status = images.ImageStatus(selector.Status)
+	}
+
+	matches := []*images.Image{}
+	err = images.List(imageClient, listOpts).EachPage(func(p pagination.Page) (bool, error) {
+		ii, err := images.ExtractImages(p)
+		if err != nil {
+			return false, fmt.Errorf("failed to extract image pages")
+		}
+		for i := range ii {
+			if nameRegex != nil && !nameRegex.MatchString(ii[i].Name) {
+				continue
+			}
+			propertiesMatch := true
+			for k, v := range selector.Properties {
+				if fmt.Sprint(ii[i].Properties[k]) != v {
+					propertiesMatch = false
+					break
+				}
+			}
+			if propertiesMatch {
+				matches = append(matches, &ii[i])
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %v", err)
+	}
+
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no images match")
+	}
+	if len(matches) > 1 {
+		if !sortable {
+			candidates := make([]string, len(matches))
+			for i, image := range matches {
+				candidates[i] = fmt.Sprintf("%s (%s)", image.Name, image.ID)
+			}
+			return nil, ambiguousMatchError("image", candidates)
+		}
+		sort.SliceStable(matches, func(i, j int) bool {
+			if descending {
+				return less(matches[j], matches[i])
+			}
+			return less(matches[i], matches[j])
+		})
+	}
+	return matches[0], nil
+}
+
+// selectFlavor finds the Nova flavor matching the selector. If more than one flavor matches, the
+// first after sorting is selected (or an error is returned if no sort key is set)
+func selectFlavor(computeClient *gophercloud.ServiceClient, selector *OpenstackFlavor) (*flavors.Flavor, error) {
+	// If ID is present, just get flavor by id
+	if selector.ID != nil {
+		flavor, err := flavors.Get(computeClient, *selector.ID).Extract()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get flavor by ID: %v", err)
+		}
+		return flavor, nil
+	}
+
+	nameRegex, err := compileNameRegex(selector.NameRegex)
+	if err != nil {
+		return nil, err
+	}
+	less, sortable := flavorSortKeys[selector.SortKey]
+	if selector.SortKey != "" && !sortable {
+		return nil, fmt.Errorf("unknown sort_key \"%s\"", selector.SortKey)
+	}
+	descending, err := validateSortDir(selector.SortDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Filter the cached flavors
+	allFlavors, err := CATALOG.Flavors(computeClient)
+	if err != nil {
+		return nil, err
+	}
+	matches := []*flavors.Flavor{}
+	for i := range allFlavors {
+		fl := &allFlavors[i]
+		if selector.Name != nil && fl.Name != *selector.Name {
+			continue
+		}
+		if nameRegex != nil && !nameRegex.MatchString(fl.Name) {
+			continue
+		}
+		if fl.VCPUs < selector.MinVCPUs || (selector.MaxVCPUs > 0 && fl.VCPUs > selector.MaxVCPUs) {
+			continue
+		}
+		if fl.RAM < selector.MinRAM || (selector.MaxRAM > 0 && fl.RAM > selector.MaxRAM) {
+			continue
+		}
+		if fl.Disk < selector.MinDisk || (selector.MaxDisk > 0 && fl.Disk > selector.MaxDisk) {
+			continue
+		}
+		if selector.IsPublic != nil && fl.IsPublic != *selector.IsPublic {
+			continue
+		}
+		matches = append(matches, fl)
+	}
+
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no flavors match")
+	}
+	if len(matches) > 1 {
+		if !sortable {
+			candidates := make([]string, len(matches))
+			for i, flavor := range matches {
+				candidates[i] = fmt.Sprintf("%s (%s)", flavor.Name, flavor.ID)
+			}
+			return nil, ambiguousMatchError("flavor", candidates)
+		}
+		sort.SliceStable(matches, func(i, j int) bool {
+			if descending {
+				return less(matches[j], matches[i])
+			}
+			return less(matches[i], matches[j])
+		})
+	}
+	return matches[0], nil
+}
+
+// selectAvailabilityZone finds the compute availability zone matching the selector. If more than one
+// zone matches, the first after sorting by name is selected (or an error is returned if no sort_dir is set)
+func selectAvailabilityZone(computeClient *gophercloud.ServiceClient, selector *OpenstackAvailabilityZone) (*availabilityzones.AvailabilityZone, error) {
+	nameRegex, err := compileNameRegex(selector.NameRegex)
+	if err != nil {
+		return nil, err
+	}
+	descending, err := validateSortDir(selector.SortDir)
+	if err != nil {
+		return nil, err
+	}
+	available := true
+	if selector.Available != nil {
+		available = *selector.Available
+	}
+
+	allZonePages, err := availabilityzones.List(computeClient).AllPages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list availability zones: %v", err)
+	}
+	allZones, err := availabilityzones.ExtractAvailabilityZones(allZonePages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list availability zones: %v", err)
+	}
+
+	matches := []*availabilityzones.AvailabilityZone{}
+	for i := range allZones {
+		zone := &allZones[i]
+		if selector.Name != nil && zone.ZoneName != *selector.Name {
+			continue
+		}
+		if nameRegex != nil && !nameRegex.MatchString(zone.ZoneName) {
+			continue
+		}
+		if zone.ZoneState.Available != available {
+			continue
+		}
+		matches = append(matches, zone)
+	}
+
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no availability zones match")
+	}
+	if len(matches) > 1 {
+		if selector.SortDir == "" {
+			candidates := make([]string, len(matches))
+			for i, zone := range matches {
+				candidates[i] = zone.ZoneName
+			}
+			return nil, ambiguousMatchError("availability zone", candidates)
+		}
+		sort.SliceStable(matches, func(i, j int) bool {
+			if descending {
+				return matches[j].ZoneName < matches[i].ZoneName
+			}
+			return matches[i].ZoneName < matches[j].ZoneName
+		})
+	}
+	return matches[0], nil
+}
+
+// selectSecurityGroup finds the Neutron security group matching the selector (exactly one must match)
+func selectSecurityGroup(networkClient *gophercloud.ServiceClient, selector *OpenstackSecurityGroup) (*groups.SecGroup, error) {
+	nameRegex, err := compileNameRegex(selector.NameRegex)
+	if err != nil {
+		return nil, err
+	}
+
+	// Filter as much as possible in Neutron
+	listOpts := groups.ListOpts{
+		ProjectID: selector.ProjectID,
+		Tags:      strings.Join(selector.Tags, ","),
+	}
+	if selector.ID != nil {
+		listOpts.ID = *selector.ID
+	}
+	if selector.Name != nil {
+		listOpts.Name = *selector.Name
+	}
+
+	matches := []groups.SecGroup{}
+	err = groups.List(networkClient, listOpts).EachPage(func(p pagination.Page) (bool, error) {
+		gg, err := groups.ExtractGroups(p)
+		if err != nil {
+			return false, fmt.Errorf("failed to extract security group pages")
+		}
+		for _, group := range gg {
+			if nameRegex != nil && !nameRegex.MatchString(group.Name) {
+				continue
+			}
+			matches = append(matches, group)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list security groups: %v", err)
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no security groups match")
+	case 1:
+		return &matches[0], nil
+	default:
+		candidates := make([]string, len(matches))
+		for i, group := range matches {
+			candidates[i] = fmt.Sprintf("%s (%s)", group.Name, group.ID)
+		}
+		return nil, ambiguousMatchError("security group", candidates)
+	}
+}
+
+// catalogValidator checks blueprint references against the live catalog, remembering what each
+// image, flavor and availability zone data object selected so they're only looked up once
+type catalogValidator struct {
+	blueprint     *OpenstackBlueprint
+	computeClient *gophercloud.ServiceClient
+	imageClient   *gophercloud.ServiceClient
+	networkClient *gophercloud.ServiceClient
+
+	images  map[string]*images.Image
+	flavors map[string]*flavors.Flavor
+	zones   map[string]*availabilityzones.AvailabilityZone
+	// Errors from selecting data objects (only reported once on the data object itself)
+	dataErrors map[string]error
+}
+
+// image gets the image referenced by a host (ID, name or key of an image data object)
+func (v *catalogValidator) image(ref string) (*images.Image, error) {
+	if o, ok := v.blueprint.Objects[ref]; ok && o.Image != nil {
+		if _, done := v.images[ref]; !done && v.dataErrors[ref] == nil {
+			image, err := selectImage(v.imageClient, o.Image)
+			v.images[ref], v.dataErrors[ref] = image, err
+		}
+		if v.dataErrors[ref] != nil {
+			return nil, fmt.Errorf("image data \"%s\" can't be selected", ref)
+		}
+		return v.images[ref], nil
+	}
+	return CATALOG.Image(v.imageClient, ref)
+}
+
+// flavor gets the flavor referenced by a host (ID, name or key of a flavor data object)
+func (v *catalogValidator) flavor(ref string) (*flavors.Flavor, error) {
+	if o, ok := v.blueprint.Objects[ref]; ok && o.Flavor != nil {
+		if _, done := v.flavors[ref]; !done && v.dataErrors[ref] == nil {
+			flavor, err := selectFlavor(v.computeClient, o.Flavor)
+			v.flavors[ref], v.dataErrors[ref] = flavor, err
+		}
+		if v.dataErrors[ref] != nil {
+			return nil, fmt.Errorf("flavor data \"%s\" can't be selected", ref)
+		}
+		return v.flavors[ref], nil
+	}
+	return CATALOG.Flavor(v.computeClient, ref)
+}
+
+// availabilityZone gets the availability zone referenced by a host (name or key of an availability zone data object)
+func (v *catalogValidator) availabilityZone(ref string) (*availabilityzones.AvailabilityZone, error) {
+	if o, ok := v.blueprint.Objects[ref]; ok && o.AvailabilityZone != nil {
+		if _, done := v.zones[ref]; !done && v.dataErrors[ref] == nil {
+			zone, err := selectAvailabilityZone(v.computeClient, o.AvailabilityZone)
+			v.zones[ref], v.dataErrors[ref] = zone, err
+		}
+		if v.dataErrors[ref] != nil {
+			return nil, fmt.Errorf("availability zone data \"%s\" can't be selected", ref)
+		}
+		return v.zones[ref], nil
+	}
+	return selectAvailabilityZone(v.computeClient, &OpenstackAvailabilityZone{
+		Name: &ref,
+	})
+}
+
+// validateHost checks the image, flavor and availability zone of a host exist and fit together
+func (v *catalogValidator) validateHost(host *OpenstackHost) []error {
+	errs := []error{}
+
+	hostImage, err := v.image(host.Image)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("image \"%s\": %v", host.Image, err))
+	} else if hostImage.Status != images.ImageStatusActive {
+		errs = append(errs, fmt.Errorf("image \"%s\" is %s (must be active)", host.Image, hostImage.Status))
+	}
+
+	hostFlavor, err := v.flavor(host.Flavor)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("flavor \"%s\": %v", host.Flavor, err))
+	}
+
+	if hostImage != nil {
+		// Hosts boot from a volume of disk_size
+		if host.DiskSize < hostImage.MinDiskGigabytes {
+			errs = append(errs, fmt.Errorf("disk_size of %dGB is too small for image \"%s\" (minimum %dGB required)", host.DiskSize, host.Image, hostImage.MinDiskGigabytes))
+		}
+		if hostFlavor != nil && hostFlavor.RAM < hostImage.MinRAMMegabytes {
+			errs = append(errs, fmt.Errorf("flavor \"%s\" has %dMB RAM but image \"%s\" requires %dMB", host.Flavor, hostFlavor.RAM, host.Image, hostImage.MinRAMMegabytes))
+		}
+	}
+
+	if host.AvailabilityZone != "" {
+		if _, err := v.availabilityZone(host.AvailabilityZone); err != nil {
+			errs = append(errs, fmt.Errorf("availability zone \"%s\": %v", host.AvailabilityZone, err))
+		}
+	}
+	return errs
+}
+
+// validateCatalog checks every image, flavor, availability zone and external network referenced by the
+// blueprint exists and is usable by the project, returning every problem found
+func validateCatalog(authClient *gophercloud.ProviderClient, blueprint *OpenstackBlueprint) (ValidationErrors, error) {
+	endpointOpts := gophercloud.EndpointOpts{
+		Region: CONFIG.RegionName,
+	}
+	computeClient, err := openstack.NewComputeV2(authClient, endpointOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compute client: %v", err)
+	}
+	imageClient, err := openstack.NewImageServiceV2(authClient, endpointOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create image client: %v", err)
+	}
+	networkClient, err := openstack.NewNetworkV2(authClient, gophercloud.EndpointOpts{
+		Name:   "neutron",
+		Region: CONFIG.RegionName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create openstack network client: %v", err)
+	}
+
+	v := &catalogValidator{
+		blueprint:     blueprint,
+		computeClient: computeClient,
+		imageClient:   imageClient,
+		networkClient: networkClient,
+		images:        make(map[string]*images.Image),
+		flavors:       make(map[string]*flavors.Flavor),
+		zones:         make(map[string]*availabilityzones.AvailabilityZone),
+		dataErrors:    make(map[string]error),
+	}
+
+	errs := ValidationErrors{}
+	for _, k := range sortedKeys(blueprint.Objects) {
+		o := blueprint.Objects[k]
+		switch {
+		// Check the catalog data objects select something
+		case o.Image != nil:
+			if _, err := v.image(k); err != nil {
+				errs.add(k, v.dataErrors[k])
+			}
+		case o.Flavor != nil:
+			if _, err := v.flavor(k); err != nil {
+				errs.add(k, v.dataErrors[k])
+			}
+		case o.AvailabilityZone != nil:
+			if _, err := v.availabilityZone(k); err != nil {
+				errs.add(k, v.dataErrors[k])
+			}
+		case o.SecurityGroup != nil:
+			if _, err := selectSecurityGroup(networkClient, o.SecurityGroup); err != nil {
+				errs.add(k, err)
+			}
+		// Check the references of created hosts and routers
+		case o.Resource != nil && o.Host != nil:
+			errs.add(k, v.validateHost(o.Host)...)
+		case o.Resource != nil && o.Router != nil:
+			if o.Router.ExternalNetwork == "" {
+				break
+			}
+			if _, ok := blueprint.Objects[o.Router.ExternalNetwork]; ok {
+				break
+			}
+			if _, err := resolveExternalNetwork(networkClient, o.Router.ExternalNetwork); err != nil {
+				errs.add(k, fmt.Errorf("external network \"%s\" isn't defined or can't be resolved: %v", o.Router.ExternalNetwork, err))
+			}
+		}
+	}
+	return errs, nil
+}
@@ -0,0 +1,110 @@
+package openstack
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+	"github.com/gophercloud/gophercloud/openstack/imageservice/v2/images"
+)
+
+func TestCatalogCacheMiss(t *testing.T) {
+	client := newTestServiceClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/flavors/new-flavor-id":
+			w.Write([]byte(`{"flavor": {"id": "new-flavor-id", "name": "new"}}`))
+		case r.URL.Path == "/flavors/detail":
+			w.Write([]byte(`{"flavors": [{"id": "small-id", "name": "small"}, {"id": "other-id", "name": "other"}]}`))
+		case r.URL.Path == "/images/new-image-id":
+			w.Write([]byte(`{"id": "new-image-id", "name": "new", "status": "active"}`))
+		case r.URL.Path == "/images" && r.URL.Query().Get("name") == "ubuntu" && r.URL.Query().Get("status") == "active":
+			w.Write([]byte(`{"images": [{"id": "ubuntu-id", "name": "ubuntu", "status": "active"}]}`))
+		case r.URL.Path == "/images":
+			w.Write([]byte(`{"images": []}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	// A fresh (empty) cache, so every lookup misses
+	cache := &catalogCache{
+		flavorList:    []flavors.Flavor{},
+		flavorsByID:   map[string]*flavors.Flavor{},
+		flavorsByName: map[string][]*flavors.Flavor{},
+		flavorsExpire: time.Now().Add(time.Hour),
+		imageList:     []images.Image{},
+		imagesByID:    map[string]*images.Image{},
+		imagesByName:  map[string][]*images.Image{},
+		imagesExpire:  time.Now().Add(time.Hour),
+	}
+
+	if fl, err := cache.Flavor(client, "new-flavor-id"); err != nil || fl.ID != "new-flavor-id" {
+		t.Errorf("flavor by ID: got %v, %v", fl, err)
+	}
+	if fl, err := cache.Flavor(client, "small"); err != nil || fl.ID != "small-id" {
+		t.Errorf("flavor by name: got %v, %v", fl, err)
+	}
+	if _, err := cache.Flavor(client, "missing"); err == nil {
+		t.Errorf("expected error for a missing flavor")
+	}
+	if img, err := cache.Image(client, "new-image-id"); err != nil || img.ID != "new-image-id" {
+		t.Errorf("image by ID: got %v, %v", img, err)
+	}
+	if img, err := cache.Image(client, "ubuntu"); err != nil || img.ID != "ubuntu-id" {
+		t.Errorf("image by name: got %v, %v", img, err)
+	}
+	if _, err := cache.Image(client, "missing"); err == nil {
+		t.Errorf("expected error for a missing image")
+	}
+}
+
+func TestSelectSecurityGroup(t *testing.T) {
+	networkClient := newTestServiceClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/security-groups" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		// Neutron applies the exact filters
+		switch {
+		case r.URL.Query().Get("name") == "web":
+			w.Write([]byte(`{"security_groups": [{"id": "web-id", "name": "web", "project_id": "abc"}]}`))
+		case r.URL.Query().Get("tags") == "lab" && r.URL.Query().Get("project_id") == "abc":
+			w.Write([]byte(`{"security_groups": [{"id": "web-id", "name": "web", "tags": ["lab"]}, {"id": "ssh-id", "name": "ssh", "tags": ["lab"]}]}`))
+		default:
+			w.Write([]byte(`{"security_groups": []}`))
+		}
+	})
+
+	tests := []struct {
+		name     string
+		selector OpenstackSecurityGroup
+		wantId   string
+		wantErr  string
+	}{
+		{name: "name", selector: OpenstackSecurityGroup{Name: strPtr("web")}, wantId: "web-id"},
+		{name: "tags and project narrowed by regex", selector: OpenstackSecurityGroup{Tags: []string{"lab"}, ProjectID: "abc", NameRegex: strPtr("^ss")}, wantId: "ssh-id"},
+		{name: "ambiguous", selector: OpenstackSecurityGroup{Tags: []string{"lab"}, ProjectID: "abc"}, wantErr: "2 security groups match"},
+		{name: "no match", selector: OpenstackSecurityGroup{Name: strPtr("missing")}, wantErr: "no security groups match"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group, err := selectSecurityGroup(networkClient, &tt.selector)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if group.ID != tt.wantId {
+				t.Errorf("got %s, want %s", group.ID, tt.wantId)
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package openstack
 import (
 	"context"
 	"fmt"
+	"text/template"
+	"time"
 
 	pgrpc "github.com/cble-platform/cble-provider-grpc/pkg/provider"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/remoteconsoles"
@@ -21,6 +23,12 @@ type ProviderOpenstackConfig struct {
 	DomainId                 string                         `yaml:"domain_id,omitempty"`
 	PreferredConsoleType     remoteconsoles.ConsoleType     `yaml:"console_type,omitempty"`
 	PreferredConsoleProtocol remoteconsoles.ConsoleProtocol `yaml:"console_protocol,omitempty"`
+	NameTemplate             string                         `yaml:"name_template,omitempty"`
+	Sweeper                  *SweeperConfig                 `yaml:"sweeper,omitempty"`
+	CatalogCacheTTL          time.Duration                  `yaml:"catalog_cache_ttl,omitempty"`
+	QuotaPreflight           bool                           `yaml:"quota_preflight,omitempty"`
+
+	nameTemplate *template.Template
 }
 
 func ConfigFromBytes(in []byte) (*ProviderOpenstackConfig, error) {
@@ -28,6 +36,11 @@ func ConfigFromBytes(in []byte) (*ProviderOpenstackConfig, error) {
 	if err := yaml.Unmarshal(in, &config); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %v", err)
 	}
+	nameTemplate, err := parseNameTemplate(config.NameTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse name_template: %v", err)
+	}
+	config.nameTemplate = nameTemplate
 	return &config, nil
 }
 
@@ -44,6 +57,9 @@ func (provider ProviderOpenstack) Configure(ctx context.Context, request *pgrpc.
 	// Set the provider config
 	CONFIG = config
 
+	// Flavors and images may differ in the new project/region
+	CATALOG.reset()
+
 	// Test the connection
 	if _, err := provider.newAuthClient(); err != nil {
 		return &pgrpc.ConfigureReply{
@@ -51,6 +67,9 @@ func (provider ProviderOpenstack) Configure(ctx context.Context, request *pgrpc.
 		}, fmt.Errorf("connection test failed: %v", err)
 	}
 
+	// (Re)schedule the orphan sweeper
+	provider.scheduleSweeper(CONFIG.Sweeper)
+
 	return &pgrpc.ConfigureReply{
 		Success: true,
 	}, nil
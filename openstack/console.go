@@ -1,80 +1,131 @@
 package openstack
 
-// func GetConsoleErrorf(request *pgrpc.GetConsoleRequest, format string, a ...any) *pgrpc.GetConsoleReply {
-// 	return &pgrpc.GetConsoleReply{
-// 		DeploymentId: request.DeploymentId,
-// 		Status:       commonGRPC.RPCStatus_FAILURE,
-// 		Console:      "",
-// 		Error: []string{
-// 			fmt.Sprintf(format, a...),
-// 		},
-// 	}
-// }
-
-// func (provider ProviderOpenstack) GetConsole(ctx context.Context, request *pgrpc.GetConsoleRequest) (*pgrpc.GetConsoleReply, error) {
-// 	logrus.Debugf("GetConsole called for deployment \"%s\" host \"%s\"", request.DeploymentId, request.HostKey)
-
-// 	// Check if the provider has been configured
-// 	if CONFIG == nil {
-// 		return nil, fmt.Errorf("cannot deploy with unconfigured provider, please call Configure()")
-// 	}
-
-// 	// Generate authenticated client session
-// 	authClient, err := provider.newAuthClient()
-// 	if err != nil {
-// 		return nil, fmt.Errorf("failed to authenticate: %v", err)
-// 	}
-
-// 	// Convert protobuf maps to go maps
-// 	deploymentVars := request.DeploymentVars.AsMap()
-// 	deploymentState := request.DeploymentState.AsMap()
-
-// 	// Check that the host state is good
-// 	if deploymentState[request.HostKey] != commonGRPC.DeployStateSUCCEEDED {
-// 		return GetConsoleErrorf(request, "host %s is not in SUCCEEDED state", request.HostKey), nil
-// 	}
-
-// 	// Check for VM ID in vars
-// 	instanceId, ok := deploymentVars[request.HostKey+"_id"]
-// 	if !ok {
-// 		return GetConsoleErrorf(request, "host %s does not have instance id", request.HostKey), nil
-// 	}
-// 	// Ensure instanceId is string
-// 	instanceIdStr, ok := instanceId.(string)
-// 	if !ok {
-// 		return GetConsoleErrorf(request, "host %s has non-string id", request.HostKey), nil
-// 	}
-
-// 	// Generate the Compute V2 client
-// 	endpointOpts := gophercloud.EndpointOpts{
-// 		Region: CONFIG.RegionName,
-// 	}
-// 	computeClient, err := openstack.NewComputeV2(authClient, endpointOpts)
-// 	if err != nil {
-// 		return GetConsoleErrorf(request, "failed to create compute v2 client: %v", err), nil
-// 	}
-// 	// Set the microversion of the compute api (min for remote consoles is 2.6)
-// 	computeClient.Microversion = "2.6"
-
-// 	// Create the remote console and return the URL
-// 	remoteConsole, err := remoteconsoles.Create(computeClient, instanceIdStr, remoteconsoles.CreateOpts{
-// 		Protocol: CONFIG.PreferredConsoleProtocol,
-// 		Type:     CONFIG.PreferredConsoleType,
-// 	}).Extract()
-// 	if err != nil {
-// 		return GetConsoleErrorf(request, "failed to create Openstack remote console: %v", err), nil
-// 	}
-// 	// Enable auto scaling on URL
-// 	finalURL := remoteConsole.URL
-// 	if !strings.Contains(finalURL, "scale=true") {
-// 		finalURL = finalURL + "&scale=true"
-// 	}
-
-// 	// Return the URL
-// 	return &pgrpc.GetConsoleReply{
-// 		DeploymentId: request.DeploymentId,
-// 		Status:       commonGRPC.RPCStatus_SUCCESS,
-// 		Console:      finalURL,
-// 		Error:       []string{},
-// 	}, nil
-// }
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	pgrpc "github.com/cble-platform/cble-provider-grpc/pkg/provider"
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/remoteconsoles"
+	"github.com/sirupsen/logrus"
+)
+
+func (provider ProviderOpenstack) GetConsole(ctx context.Context, request *pgrpc.GetConsoleRequest) (*pgrpc.GetConsoleReply, error) {
+	logrus.Debugf("----- GetConsole called for resource \"%s\" -----", request.Resource.Id)
+
+	// Check if the provider has been configured
+	if CONFIG == nil {
+		return &pgrpc.GetConsoleReply{
+			Success: false,
+			Error:   Errorf("cannot get console with unconfigured provider, please call Configure()"),
+		}, nil
+	}
+
+	// Generate authenticated client session
+	authClient, err := provider.newAuthClient()
+	if err != nil {
+		return &pgrpc.GetConsoleReply{
+			Success: false,
+			Error:   Errorf("failed to authenticate: %v", err),
+		}, nil
+	}
+
+	// Unmarshal the object YAML as struct
+	var object *OpenstackObject
+	object, err = parseObject(request.Resource.Key, request.Resource.Object)
+	if err != nil {
+		return &pgrpc.GetConsoleReply{
+			Success: false,
+			Error:   Errorf("failed to unmarshal resource object: %v", err),
+		}, nil
+	}
+
+	// Check this is a resource (not data)
+	if object.Resource == nil {
+		return &pgrpc.GetConsoleReply{
+			Success: false,
+			Error:   Errorf("cannot get console for data object"),
+		}, nil
+	}
+
+	// Convert vars stored for a superseded version of the type
+	request.Vars, err = migrateVars(object, request.Vars)
+	if err != nil {
+		return &pgrpc.GetConsoleReply{
+			Success: false,
+			Error:   Errorf("%v", err),
+		}, nil
+	}
+
+	// Get the handler of the resource type
+	handler, err := getResourceHandler(*object.Resource)
+	if err != nil {
+		return &pgrpc.GetConsoleReply{
+			Success: false,
+			Error:   Errorf("%v", err),
+		}, nil
+	}
+
+	// Get the console (only supported by some types, e.g. hosts)
+	console, err := handler.Console(ctx, &provider, authClient, request, object)
+	if err != nil {
+		return &pgrpc.GetConsoleReply{
+			Success: false,
+			Error:   Errorf(err.Error()),
+		}, nil
+	}
+
+	return &pgrpc.GetConsoleReply{
+		Success: true,
+		Error:   nil,
+		Console: console,
+	}, nil
+}
+
+func (provider ProviderOpenstack) getHostConsole(ctx context.Context, authClient *gophercloud.ProviderClient, request *pgrpc.GetConsoleRequest, object *OpenstackObject) (string, error) {
+	logrus.Debugf("Getting console for host \"%s\"", request.Resource.Id)
+
+	// Get the Openstack server ID from vars
+	osServerId, ok := request.Vars["id"]
+	if !ok {
+		return "", fmt.Errorf("no ID found for resource")
+	}
+
+	// Generate the Compute V2 client
+	endpointOpts := gophercloud.EndpointOpts{
+		Region: CONFIG.RegionName,
+	}
+	computeClient, err := openstack.NewComputeV2(authClient, endpointOpts)
+	if err != nil {
+		return "", fmt.Errorf("failed to create compute client: %v", err)
+	}
+	// Set the microversion of the compute api (min for remote consoles is 2.6)
+	computeClient.Microversion = "2.6"
+
+	// Default to a noVNC console
+	consoleProtocol := CONFIG.PreferredConsoleProtocol
+	if consoleProtocol == "" {
+		consoleProtocol = remoteconsoles.ConsoleProtocolVNC
+	}
+	consoleType := CONFIG.PreferredConsoleType
+	if consoleType == "" {
+		consoleType = remoteconsoles.ConsoleTypeNoVNC
+	}
+
+	// Create the remote console and return the URL
+	remoteConsole, err := remoteconsoles.Create(computeClient, osServerId, remoteconsoles.CreateOpts{
+		Protocol: consoleProtocol,
+		Type:     consoleType,
+	}).Extract()
+	if err != nil {
+		return "", fmt.Errorf("failed to create remote console: %v", err)
+	}
+	// Enable auto scaling on URL
+	finalURL := remoteConsole.URL
+	if !strings.Contains(finalURL, "scale=true") {
+		finalURL = finalURL + "&scale=true"
+	}
+	return finalURL, nil
+}
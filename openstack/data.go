@@ -3,7 +3,11 @@ package openstack
 import (
 	"context"
 	"fmt"
+	"net/netip"
 	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	pgrpc "github.com/cble-platform/cble-provider-grpc/pkg/provider"
 	"github.com/gophercloud/gophercloud"
@@ -14,7 +18,6 @@ import (
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/subnets"
 	"github.com/gophercloud/gophercloud/pagination"
 	"github.com/sirupsen/logrus"
-	"gopkg.in/yaml.v3"
 )
 
 func (provider ProviderOpenstack) RetrieveData(ctx context.Context, request *pgrpc.RetrieveDataRequest) (*pgrpc.RetrieveDataReply, error) {
@@ -39,7 +42,7 @@ func (provider ProviderOpenstack) RetrieveData(ctx context.Context, request *pgr
 
 	// Unmarshal the object YAML as struct
 	var object *OpenstackObject
-	err = yaml.Unmarshal(request.Resource.Object, &object)
+	object, err = parseObject(request.Resource.Key, request.Resource.Object)
 	if err != nil {
 		return &pgrpc.RetrieveDataReply{
 			Success: false,
@@ -55,39 +58,36 @@ func (provider ProviderOpenstack) RetrieveData(ctx context.Context, request *pgr
 		}, nil
 	}
 
-	var updatedVars map[string]string
+	// Convert vars stored for a superseded version of the type
+	request.Vars, err = migrateVars(object, request.Vars)
+	if err != nil {
+		return &pgrpc.RetrieveDataReply{
+			Success: false,
+			Error:   Errorf("%v", err),
+		}, nil
+	}
 
-	// Deploy the resource based on the type of data
-	switch *object.Data {
-	// HOST
-	case OpenstackResourceTypeHost:
-		// Deploy host
-		if updatedVars, err = provider.retrieveHostData(ctx, authClient, request, object, request.Vars, request.DependencyVars); err != nil {
-			return &pgrpc.RetrieveDataReply{
-				Success: false,
-				Error:   Errorf("failed to retrieve host data: %v", err),
-			}, nil
-		}
-	// NETWORK
-	case OpenstackResourceTypeNetwork:
-		// Deploy network
-		if updatedVars, err = provider.retrieveNetworkData(ctx, authClient, request, object, request.Vars, request.DependencyVars); err != nil {
-			return &pgrpc.RetrieveDataReply{
-				Success: false,
-				Error:   Errorf("failed to retrieve network data: %v", err),
-			}, nil
-		}
-	// ROUTER
-	case OpenstackResourceTypeRouter:
-		// Deploy router
-		if updatedVars, err = provider.retrieveRouterData(ctx, authClient, request, object, request.Vars, request.DependencyVars); err != nil {
-			return &pgrpc.RetrieveDataReply{
-				Success: false,
-				Error:   Errorf("failed to retrieve router data: %v", err),
-			}, nil
-		}
+	// Get the handler of the data type
+	handler, err := getResourceHandler(*object.Data)
+	if err != nil {
+		return &pgrpc.RetrieveDataReply{
+			Success: false,
+			Error:   Errorf("%v", err),
+		}, nil
 	}
 
+	// Retrieve the data
+	updatedVars, err := handler.Retrieve(ctx, &provider, authClient, request, object, request.Vars, request.DependencyVars)
+	if err != nil {
+		return &pgrpc.RetrieveDataReply{
+			Success: false,
+			Error:   Errorf("failed to retrieve %s data: %v", object.Data.ShortName(), err),
+		}, nil
+	}
+
+	// Save the type version the vars were written by
+	updatedVars[typeVar] = string(*object.Data)
+
 	// Return the updated vars
 	return &pgrpc.RetrieveDataReply{
 		Success:     true,
@@ -124,49 +124,113 @@ func (provider *ProviderOpenstack) retrieveHostData(ctx context.Context, authCli
 		}
 	} else {
 		listOpts := servers.ListOpts{}
-		// Filter on name
+		// Filter on name (Nova treats this as a regex, so it's checked exactly below)
+		name := ""
 		if object.Host.Name != nil {
-			listOpts.Name = *object.Host.Name
+			name = *object.Host.Name
 		} else if object.Host.Hostname != "" {
-			listOpts.Name = object.Host.Hostname
+			name = object.Host.Hostname
+		}
+		if name != "" {
+			listOpts.Name = "^" + regexp.QuoteMeta(name) + "$"
 		}
-		// Filter on IP regex
-		if len(object.Host.Networks) > 0 {
-			ipRegex := "(" // Add open group tag
-			i := 0
-			for _, network := range object.Host.Networks {
-				if network.IP != nil {
-					ipRegex = ipRegex + fmt.Sprintf("(%s)", regexp.QuoteMeta(network.IP.String()))
-					// Add an OR regex if not last IP
-					if i < len(object.Host.Networks)-1 {
-						ipRegex = ipRegex + "|"
-					}
+		// Collect all IPs the server must have
+		ips := []netip.Addr{}
+		for _, network := range object.Host.Networks {
+			if network.IP != nil {
+				ips = append(ips, *network.IP)
+			}
+		}
+		listClient := computeClient
+		filter := object.Host.Filter
+		if filter != nil {
+			if filter.IP != nil {
+				ips = append(ips, *filter.IP)
+			}
+			if len(filter.Tags) > 0 {
+				listClient = withComputeMicroversion(computeClient, "2.26")
+				listOpts.Tags = strings.Join(filter.Tags, ",")
+			}
+			if filter.ProjectID != "" {
+				// Only list other projects (admin only) when filtering on another project
+				osProjectId, err := projectID(authClient)
+				if err != nil {
+					return nil, err
 				}
-				i++
+				listOpts.TenantID = filter.ProjectID
+				listOpts.AllTenants = filter.ProjectID != osProjectId
+			}
+			listOpts.Status = filter.Status
+		}
+		// Filter on the first IP (Nova treats this as a regex, so all IPs are checked exactly below)
+		if len(ips) > 0 {
+			if ips[0].Is4() {
+				listOpts.IP = "^" + regexp.QuoteMeta(ips[0].String()) + "$"
+			} else {
+				listOpts.IP6 = "^" + regexp.QuoteMeta(ips[0].String()) + "$"
 			}
-			ipRegex = ipRegex + ")" // Add closing group tag
-			listOpts.IP = ipRegex
 		}
-		err = servers.List(computeClient, listOpts).EachPage(func(p pagination.Page) (bool, error) {
+
+		matches := []servers.Server{}
+		err = servers.List(listClient, listOpts).EachPage(func(p pagination.Page) (bool, error) {
 			s, err := servers.ExtractServers(p)
 			if err != nil {
 				return false, fmt.Errorf("failed to extract server pages")
 			}
-
-			// Return the first result
-			if len(s) > 0 {
-				openstackServer = &s[0]
-				return false, nil
-			} else {
-				return false, fmt.Errorf("failed to set server from page")
+			for _, server := range s {
+				if serverMatches(&server, name, ips, filter) {
+					matches = append(matches, server)
+				}
 			}
+			return true, nil
 		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to retrieve server: %s", err)
 		}
+
+		// Require exactly one match
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("failed to retrieve server: no servers match")
+		}
+		if len(matches) > 1 {
+			candidates := make([]string, len(matches))
+			for i, server := range matches {
+				candidates[i] = fmt.Sprintf("%s (%s)", server.Name, server.ID)
+			}
+			return nil, ambiguousMatchError("server", candidates)
+		}
+		openstackServer = &matches[0]
+	}
+
+	// Map the IDs of known networks to their keys
+	networkKeys := make(map[string]string)
+	for k := range object.Host.Networks {
+		if networkVars, ok := dependencyVars[k]; ok {
+			if networkId, exists := networkVars.Vars["id"]; exists {
+				networkKeys[networkId] = k
+			}
+		}
+	}
+
+	// Generate the Network V2 client
+	networkClient, err := openstack.NewNetworkV2(authClient, gophercloud.EndpointOpts{
+		Name:   "neutron",
+		Region: CONFIG.RegionName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create openstack network client: %v", err)
+	}
+
+	// Get the server details (including the availability zone)
+	hostServer, err := getServerWithExt(computeClient, openstackServer.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get openstack server: %v", err)
 	}
 
-	updatedVars["id"] = openstackServer.ID
+	// Save the server details and addresses into vars
+	if err = setHostVars(networkClient, hostServer, networkKeys, updatedVars); err != nil {
+		return nil, fmt.Errorf("failed to get host addresses: %v", err)
+	}
 
 	logrus.Debugf("Successfully retrieved host %s as server %s (%s)", request.Resource.Key, openstackServer.Name, openstackServer.ID)
 
@@ -206,30 +270,43 @@ func (provider *ProviderOpenstack) retrieveNetworkData(ctx context.Context, auth
 		if object.Network.Name != nil {
 			listOpts.Name = *object.Network.Name
 		}
+		if filter := object.Network.Filter; filter != nil {
+			listOpts.Tags = strings.Join(filter.Tags, ",")
+			listOpts.ProjectID = filter.ProjectID
+			listOpts.Status = filter.Status
+		}
+
+		matches := []networks.Network{}
 		err = networks.List(networkClient, listOpts).EachPage(func(p pagination.Page) (bool, error) {
 			n, err := networks.ExtractNetworks(p)
 			if err != nil {
 				return false, fmt.Errorf("failed to extract network pages")
 			}
-
-			// Return the first result
-			if len(n) > 0 {
-				openstackNetwork = &n[0]
-				return false, nil
-			} else {
-				return false, fmt.Errorf("failed to set network from page")
-			}
+			matches = append(matches, n...)
+			return true, nil
 		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to retrieve network: %s", err)
 		}
+
+		// Require exactly one match
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("failed to retrieve network: no networks match")
+		}
+		if len(matches) > 1 {
+			candidates := make([]string, len(matches))
+			for i, network := range matches {
+				candidates[i] = fmt.Sprintf("%s (%s)", network.Name, network.ID)
+			}
+			return nil, ambiguousMatchError("network", candidates)
+		}
+		openstackNetwork = &matches[0]
 	}
 
 	updatedVars["id"] = openstackNetwork.ID
 
-	var openstackSubnet *subnets.Subnet
-
 	// Get the subnets of the network
+	networkSubnets := []subnets.Subnet{}
 	err = subnets.List(networkClient, subnets.ListOpts{
 		NetworkID: openstackNetwork.ID,
 	}).EachPage(func(p pagination.Page) (bool, error) {
@@ -237,20 +314,43 @@ func (provider *ProviderOpenstack) retrieveNetworkData(ctx context.Context, auth
 		if err != nil {
 			return false, fmt.Errorf("failed to extract subnet pages")
 		}
-
-		// Return the first result
-		if len(s) > 0 {
-			openstackSubnet = &s[0]
-			return false, nil
-		} else {
-			return false, fmt.Errorf("failed to set subnet from page")
-		}
+		networkSubnets = append(networkSubnets, s...)
+		return true, nil
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve subnet: %s", err)
 	}
 
-	updatedVars["subnet_id"] = openstackSubnet.ID
+	// Pick the subnet matching the CIDR (if set), otherwise prefer IPv4 subnets
+	candidateSubnets := []subnets.Subnet{}
+	for _, subnet := range networkSubnets {
+		if object.Network.Subnet.IsValid() {
+			if subnet.CIDR == object.Network.Subnet.String() {
+				candidateSubnets = append(candidateSubnets, subnet)
+			}
+		} else if subnet.IPVersion == 4 {
+			candidateSubnets = append(candidateSubnets, subnet)
+		}
+	}
+	if len(candidateSubnets) == 0 && !object.Network.Subnet.IsValid() {
+		candidateSubnets = networkSubnets
+	}
+	if len(candidateSubnets) == 0 {
+		return nil, fmt.Errorf("failed to retrieve subnet: no subnets match")
+	}
+	if len(candidateSubnets) > 1 {
+		candidates := make([]string, len(candidateSubnets))
+		for i, subnet := range candidateSubnets {
+			candidates[i] = fmt.Sprintf("%s (%s)", subnet.CIDR, subnet.ID)
+		}
+		return nil, ambiguousMatchError("subnet", candidates)
+	}
+	openstackSubnet := &candidateSubnets[0]
+
+	// Save the network and subnet details into vars
+	if err = setNetworkVars(networkClient, openstackNetwork.ID, openstackSubnet, updatedVars); err != nil {
+		return nil, fmt.Errorf("failed to get network details: %v", err)
+	}
 
 	logrus.Debugf("Successfully retrieved network %s as network %s (%s)", request.Resource.Key, openstackNetwork.Name, openstackNetwork.ID)
 
@@ -290,28 +390,233 @@ func (provider *ProviderOpenstack) retrieveRouterData(ctx context.Context, authC
 		if object.Router.Name != nil {
 			listOpts.Name = *object.Router.Name
 		}
+		if filter := object.Router.Filter; filter != nil {
+			listOpts.Tags = strings.Join(filter.Tags, ",")
+			listOpts.ProjectID = filter.ProjectID
+			listOpts.Status = filter.Status
+		}
+
+		matches := []routers.Router{}
 		err = routers.List(networkClient, listOpts).EachPage(func(p pagination.Page) (bool, error) {
 			n, err := routers.ExtractRouters(p)
 			if err != nil {
 				return false, fmt.Errorf("failed to extract router pages")
 			}
-
-			// Return the first result
-			if len(n) > 0 {
-				openstackRouter = &n[0]
-				return false, nil
-			} else {
-				return false, fmt.Errorf("failed to set router from page")
-			}
+			matches = append(matches, n...)
+			return true, nil
 		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to retrieve router: %s", err)
 		}
+
+		// Require exactly one match
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("failed to retrieve router: no routers match")
+		}
+		if len(matches) > 1 {
+			candidates := make([]string, len(matches))
+			for i, router := range matches {
+				candidates[i] = fmt.Sprintf("%s (%s)", router.Name, router.ID)
+			}
+			return nil, ambiguousMatchError("router", candidates)
+		}
+		openstackRouter = &matches[0]
 	}
 
-	updatedVars["id"] = openstackRouter.ID
+	// Map the IDs of known networks to their keys
+	networkKeys := make(map[string]string)
+	for k := range object.Router.Networks {
+		if networkVars, ok := dependencyVars[k]; ok {
+			if networkId, exists := networkVars.Vars["id"]; exists {
+				networkKeys[networkId] = k
+			}
+		}
+	}
+
+	// Save the router details and interface addresses into vars
+	if err = setRouterVars(networkClient, openstackRouter.ID, networkKeys, updatedVars); err != nil {
+		return nil, fmt.Errorf("failed to get router details: %v", err)
+	}
 
 	logrus.Debugf("Successfully retrieved router %s as router %s (%s)", request.Resource.Key, openstackRouter.Name, openstackRouter.ID)
 
 	return updatedVars, nil
 }
+
+// serverMatches checks the filters which Nova can't match exactly (name, IPs and metadata)
+func serverMatches(server *servers.Server, name string, ips []netip.Addr, filter *OpenstackDataFilter) bool {
+	if name != "" && server.Name != name {
+		return false
+	}
+
+	// Collect all addresses of the server
+	serverIPs := make(map[netip.Addr]bool)
+	for _, networkAddresses := range server.Addresses {
+		addresses, ok := networkAddresses.([]interface{})
+		if !ok {
+			continue
+		}
+		for _, address := range addresses {
+			addressMap, ok := address.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			addr, ok := addressMap["addr"].(string)
+			if !ok {
+				continue
+			}
+			if ip, err := netip.ParseAddr(addr); err == nil {
+				serverIPs[ip] = true
+			}
+		}
+	}
+	for _, ip := range ips {
+		if !serverIPs[ip] {
+			return false
+		}
+	}
+
+	if filter != nil {
+		for k, v := range filter.Metadata {
+			if server.Metadata[k] != v {
+				return false
+			}
+		}
+		if filter.ProjectID != "" && server.TenantID != filter.ProjectID {
+			return false
+		}
+	}
+	return true
+}
+
+func (provider *ProviderOpenstack) retrieveImageData(ctx context.Context, authClient *gophercloud.ProviderClient, request *pgrpc.RetrieveDataRequest, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	logrus.Debugf("Retrieving image data \"%s\"", request.Resource.Key)
+
+	// Initialize updated vars to old vars
+	updatedVars := make(map[string]string)
+	for k, v := range vars {
+		updatedVars[k] = v
+	}
+
+	// Generate the Image V2 client
+	imageClient, err := openstack.NewImageServiceV2(authClient, gophercloud.EndpointOpts{
+		Region: CONFIG.RegionName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create image client: %v", err)
+	}
+
+	image, err := selectImage(imageClient, object.Image)
+	if err != nil {
+		return nil, err
+	}
+
+	// Store the image attributes
+	updatedVars["id"] = image.ID
+	updatedVars["name"] = image.Name
+	updatedVars["status"] = string(image.Status)
+	updatedVars["visibility"] = string(image.Visibility)
+	updatedVars["min_disk"] = strconv.Itoa(image.MinDiskGigabytes)
+	updatedVars["min_ram"] = strconv.Itoa(image.MinRAMMegabytes)
+	updatedVars["size"] = strconv.FormatInt(image.SizeBytes, 10)
+	updatedVars["created_at"] = image.CreatedAt.Format(time.RFC3339)
+	updatedVars["tags"] = strings.Join(image.Tags, ",")
+
+	return updatedVars, nil
+}
+
+func (provider *ProviderOpenstack) retrieveFlavorData(ctx context.Context, authClient *gophercloud.ProviderClient, request *pgrpc.RetrieveDataRequest, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	logrus.Debugf("Retrieving flavor data \"%s\"", request.Resource.Key)
+
+	// Initialize updated vars to old vars
+	updatedVars := make(map[string]string)
+	for k, v := range vars {
+		updatedVars[k] = v
+	}
+
+	// Generate the Compute V2 client
+	computeClient, err := openstack.NewComputeV2(authClient, gophercloud.EndpointOpts{
+		Region: CONFIG.RegionName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compute client: %v", err)
+	}
+
+	flavor, err := selectFlavor(computeClient, object.Flavor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Store the flavor attributes
+	updatedVars["id"] = flavor.ID
+	updatedVars["name"] = flavor.Name
+	updatedVars["vcpus"] = strconv.Itoa(flavor.VCPUs)
+	updatedVars["ram"] = strconv.Itoa(flavor.RAM)
+	updatedVars["disk"] = strconv.Itoa(flavor.Disk)
+	updatedVars["ephemeral"] = strconv.Itoa(flavor.Ephemeral)
+	updatedVars["is_public"] = strconv.FormatBool(flavor.IsPublic)
+
+	return updatedVars, nil
+}
+
+func (provider *ProviderOpenstack) retrieveAvailabilityZoneData(ctx context.Context, authClient *gophercloud.ProviderClient, request *pgrpc.RetrieveDataRequest, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	logrus.Debugf("Retrieving availability zone data \"%s\"", request.Resource.Key)
+
+	// Initialize updated vars to old vars
+	updatedVars := make(map[string]string)
+	for k, v := range vars {
+		updatedVars[k] = v
+	}
+
+	// Generate the Compute V2 client
+	computeClient, err := openstack.NewComputeV2(authClient, gophercloud.EndpointOpts{
+		Region: CONFIG.RegionName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compute client: %v", err)
+	}
+
+	zone, err := selectAvailabilityZone(computeClient, object.AvailabilityZone)
+	if err != nil {
+		return nil, err
+	}
+
+	// Store the availability zone attributes
+	updatedVars["name"] = zone.ZoneName
+	updatedVars["available"] = strconv.FormatBool(zone.ZoneState.Available)
+
+	return updatedVars, nil
+}
+
+func (provider *ProviderOpenstack) retrieveSecurityGroupData(ctx context.Context, authClient *gophercloud.ProviderClient, request *pgrpc.RetrieveDataRequest, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	logrus.Debugf("Retrieving security group data \"%s\"", request.Resource.Key)
+
+	// Initialize updated vars to old vars
+	updatedVars := make(map[string]string)
+	for k, v := range vars {
+		updatedVars[k] = v
+	}
+
+	// Generate the Network V2 client
+	networkClient, err := openstack.NewNetworkV2(authClient, gophercloud.EndpointOpts{
+		Name:   "neutron",
+		Region: CONFIG.RegionName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create openstack network client: %v", err)
+	}
+
+	group, err := selectSecurityGroup(networkClient, object.SecurityGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	// Store the security group attributes
+	updatedVars["id"] = group.ID
+	updatedVars["name"] = group.Name
+	updatedVars["description"] = group.Description
+	updatedVars["project_id"] = group.ProjectID
+	updatedVars["tags"] = strings.Join(group.Tags, ",")
+
+	return updatedVars, nil
+}
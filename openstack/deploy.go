@@ -3,21 +3,20 @@ package openstack
 import (
 	"context"
 	"fmt"
-	"time"
+	"net/netip"
 
 	pgrpc "github.com/cble-platform/cble-provider-grpc/pkg/provider"
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/bootfromvolume"
-	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
-	"github.com/gophercloud/gophercloud/openstack/compute/v2/images"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/external"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/routers"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/subnets"
+	"github.com/gophercloud/gophercloud/pagination"
 	"github.com/sirupsen/logrus"
-	"gopkg.in/yaml.v3"
 )
 
 func (provider ProviderOpenstack) DeployResource(ctx context.Context, request *pgrpc.DeployResourceRequest) (*pgrpc.DeployResourceReply, error) {
@@ -42,7 +41,7 @@ func (provider ProviderOpenstack) DeployResource(ctx context.Context, request *p
 
 	// Unmarshal the object YAML as struct
 	var object *OpenstackObject
-	err = yaml.Unmarshal(request.Resource.Object, &object)
+	object, err = parseObject(request.Resource.Key, request.Resource.Object)
 	if err != nil {
 		return &pgrpc.DeployResourceReply{
 			Success: false,
@@ -58,39 +57,53 @@ func (provider ProviderOpenstack) DeployResource(ctx context.Context, request *p
 		}, nil
 	}
 
-	var updatedVars map[string]string
+	// Convert vars stored for a superseded version of the type
+	request.Vars, err = migrateVars(object, request.Vars)
+	if err != nil {
+		return &pgrpc.DeployResourceReply{
+			Success: false,
+			Error:   Errorf("%v", err),
+		}, nil
+	}
 
-	// Deploy the resource based on the type of resource
-	switch *object.Resource {
-	// HOST
-	case OpenstackResourceTypeHost:
-		// Deploy host
-		if updatedVars, err = provider.deployHost(ctx, authClient, request, object, request.Vars, request.DependencyVars); err != nil {
-			return &pgrpc.DeployResourceReply{
-				Success: false,
-				Error:   Errorf("failed to deploy host: %v", err),
-			}, nil
-		}
-	// NETWORK
-	case OpenstackResourceTypeNetwork:
-		// Deploy network
-		if updatedVars, err = provider.deployNetwork(ctx, authClient, request, object, request.Vars, request.DependencyVars); err != nil {
+	// Get the handler of the resource type
+	handler, err := getResourceHandler(*object.Resource)
+	if err != nil {
+		return &pgrpc.DeployResourceReply{
+			Success: false,
+			Error:   Errorf("%v", err),
+		}, nil
+	}
+
+	// Dependents only see the Openstack state of their dependencies
+	request.DependencyVars = withoutBookkeepingVars(request.DependencyVars)
+
+	var updatedVars map[string]string
+	if _, deployed := savedDeployedObject(request.Vars); deployed && request.Vars["id"] != "" {
+		// Update the already deployed resource in place (replacing it only if required)
+		updatedVars, err = provider.updateResource(ctx, authClient, request, object)
+		if err != nil {
 			return &pgrpc.DeployResourceReply{
 				Success: false,
-				Error:   Errorf("failed to deploy network: %v", err),
+				Error:   Errorf("failed to update %s: %v", object.Resource.ShortName(), err),
 			}, nil
 		}
-	// ROUTER
-	case OpenstackResourceTypeRouter:
-		// Deploy router
-		if updatedVars, err = provider.deployRouter(ctx, authClient, request, object, request.Vars, request.DependencyVars); err != nil {
+	} else {
+		// Deploy the resource
+		updatedVars, err = handler.Deploy(ctx, &provider, authClient, request, object, request.Vars, request.DependencyVars)
+		if err != nil {
 			return &pgrpc.DeployResourceReply{
 				Success: false,
-				Error:   Errorf("failed to deploy router: %v", err),
+				Error:   Errorf("failed to deploy %s: %v", object.Resource.ShortName(), err),
 			}, nil
 		}
 	}
 
+	// Save the deployed object so the next deploy can be compared against it
+	updatedVars[deployedObjectVar] = string(request.Resource.Object)
+	// Save the type version the vars were written by
+	updatedVars[typeVar] = string(*object.Resource)
+
 	// Return the updated vars
 	return &pgrpc.DeployResourceReply{
 		Success:     true,
@@ -117,60 +130,58 @@ func (provider *ProviderOpenstack) deployHost(ctx context.Context, authClient *g
 		return nil, fmt.Errorf("failed to create compute client: %v", err)
 	}
 
-	var hostFlavor *flavors.Flavor = nil
-	allFlavorPages, err := flavors.ListDetail(computeClient, nil).AllPages()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get host flavor \"%s\": %v", object.Host.Flavor, err)
+	// Image, flavor and availability zone may reference data objects by key
+	hostImageRef := object.Host.Image
+	if imageId, ok := dependencyVar(dependencyVars, object.Host.Image, "id"); ok {
+		hostImageRef = imageId
 	}
-	allFlavors, err := flavors.ExtractFlavors(allFlavorPages)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get host flavor \"%s\": %v", object.Host.Flavor, err)
+	hostFlavorRef := object.Host.Flavor
+	if flavorId, ok := dependencyVar(dependencyVars, object.Host.Flavor, "id"); ok {
+		hostFlavorRef = flavorId
 	}
-	for _, fl := range allFlavors {
-		if fl.Name == object.Host.Flavor || fl.ID == object.Host.Flavor {
-			hostFlavor = &fl
-			break
-		}
+	hostAvailabilityZone := object.Host.AvailabilityZone
+	if zoneName, ok := dependencyVar(dependencyVars, object.Host.AvailabilityZone, "name"); ok {
+		hostAvailabilityZone = zoneName
 	}
-	if hostFlavor == nil {
-		return nil, fmt.Errorf("failed to get host flavor \"%s\": flavor not found", object.Host.Flavor)
+
+	hostFlavor, err := CATALOG.Flavor(computeClient, hostFlavorRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get host flavor \"%s\": %v", object.Host.Flavor, err)
 	}
 
 	logrus.Debugf("got flavor %s (%s)", hostFlavor.Name, hostFlavor.ID)
 
-	var hostImage *images.Image = nil
-	allImagePages, err := images.ListDetail(computeClient, nil).AllPages()
+	// Generate the Image V2 client
+	imageClient, err := openstack.NewImageServiceV2(authClient, endpointOpts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get host image \"%s\": %v", object.Host.Image, err)
+		return nil, fmt.Errorf("failed to create image client: %v", err)
 	}
-	allImages, err := images.ExtractImages(allImagePages)
+
+	hostImage, err := CATALOG.Image(imageClient, hostImageRef)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get host image \"%s\": %v", object.Host.Image, err)
 	}
-	for _, img := range allImages {
-		if img.Name == object.Host.Image || img.ID == object.Host.Image {
-			hostImage = &img
-			break
-		}
-	}
-	if hostImage == nil {
-		return nil, fmt.Errorf("failed to get host image \"%s\": image not found", object.Host.Image)
-	}
 
 	// Check if the image requires more space than provided
-	if object.Host.DiskSize < hostImage.MinDisk {
-		return nil, fmt.Errorf("host disk size is too small for image (minimum %dGB required)", hostImage.MinDisk)
+	if object.Host.DiskSize < hostImage.MinDiskGigabytes {
+		return nil, fmt.Errorf("host disk size is too small for image (minimum %dGB required)", hostImage.MinDiskGigabytes)
 	}
 
 	logrus.Debugf("got image %s (%s)", hostImage.Name, hostImage.ID)
 
-	// Use either key or provided name as instance name
+	// Use either hostname, key or provided name as instance name
 	instanceName := object.Host.Hostname
+	if instanceName == "" {
+		instanceName = request.Resource.Key
+	}
 	if object.Host.Name != nil {
 		instanceName = *object.Host.Name
 	}
-	// Prepend the first 8 bytes of deployment ID
-	instanceName = request.Deployment.Id[:8] + "-" + instanceName
+	// Generate the instance name from the name template
+	instanceName, err = objectName(request.Deployment, request.Resource, instanceName)
+	if err != nil {
+		return nil, err
+	}
 
 	// Configure the volume to clone from the image
 	blockOps := []bootfromvolume.BlockDevice{
@@ -186,6 +197,7 @@ func (provider *ProviderOpenstack) deployHost(ctx context.Context, authClient *g
 
 	// Create network mappings for each network attachment
 	hostNetworks := []servers.Network{}
+	networkKeys := make(map[string]string)
 	for k, networkAttachment := range object.Host.Networks {
 		// Extract the network vars from dependencyVars
 		networkVars, ok := dependencyVars[k]
@@ -197,6 +209,7 @@ func (provider *ProviderOpenstack) deployHost(ctx context.Context, authClient *g
 		if !exists {
 			return nil, fmt.Errorf("ID unknown for network \"%s\"", k)
 		}
+		networkKeys[networkId] = k
 		PLACEHOLDER_NET := servers.Network{
 			UUID: networkId,
 		}
@@ -208,11 +221,13 @@ func (provider *ProviderOpenstack) deployHost(ctx context.Context, authClient *g
 
 	// Configure the instance options
 	hostOps := servers.CreateOpts{
-		Name:      instanceName,
-		ImageRef:  hostImage.ID,
-		FlavorRef: hostFlavor.ID,
-		UserData:  object.Host.UserData,
-		Networks:  hostNetworks,
+		Name:             instanceName,
+		ImageRef:         hostImage.ID,
+		FlavorRef:        hostFlavor.ID,
+		UserData:         object.Host.UserData,
+		Networks:         hostNetworks,
+		Metadata:         objectMetadata(request.Deployment, request.Resource),
+		AvailabilityZone: hostAvailabilityZone,
 	}
 
 	// Create the host
@@ -228,26 +243,49 @@ func (provider *ProviderOpenstack) deployHost(ctx context.Context, authClient *g
 	// Save the deployed host into vars
 	updatedVars["id"] = deployedServer.ID
 
-	// Wait for server to be in ACTIVE state
-	for {
-		// Get the updated server from Openstack
-		deployedServer, err = servers.Get(computeClient, deployedServer.ID).Extract()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get openstack server status: %v", err)
-		}
-		if deployedServer.Status == "ERROR" {
-			// Something happened and this failed
-			return nil, fmt.Errorf("failed to deploy host: server in ERROR state")
-		}
-		if deployedServer.Status == "ACTIVE" {
-			// Server deployed properly
-			break
+	// Wait for server to be in ACTIVE state (a server in ERROR state fails the deploy)
+	if _, err := waitForServerStatus(computeClient, deployedServer.ID, "ACTIVE", "ERROR"); err != nil {
+		return nil, fmt.Errorf("failed to deploy host: %v", err)
+	}
+
+	// Generate the Network V2 client
+	networkClient, err := openstack.NewNetworkV2(authClient, gophercloud.EndpointOpts{
+		Name:   "neutron",
+		Region: CONFIG.RegionName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create openstack network client: %v", err)
+	}
+
+	// Get the server details (including the availability zone)
+	finalServer, err := getServerWithExt(computeClient, deployedServer.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get openstack server: %v", err)
+	}
+
+	// Save the server details and addresses into vars
+	if err = setHostVars(networkClient, finalServer, networkKeys, updatedVars); err != nil {
+		return nil, fmt.Errorf("failed to get host addresses: %v", err)
+	}
+	// Boot from volume servers don't report an image, so save the one used
+	updatedVars["image_id"] = hostImage.ID
+
+	// Tag the host volumes (tags are best effort, so the server isn't leaked if Cinder is unavailable)
+	blockStorageClient, err := openstack.NewBlockStorageV3(authClient, endpointOpts)
+	if err != nil {
+		logrus.Warnf("failed to create block storage client, not tagging volumes of host %s: %v", request.Resource.Key, err)
+	} else {
+		tagServerVolumes(computeClient, blockStorageClient, deployedServer.ID, objectMetadata(request.Deployment, request.Resource))
+	}
+
+	// Tag the host ports
+	for k := range object.Host.Networks {
+		if portId, ok := updatedVars[k+"_port_id"]; ok {
+			tagNetworkObject(networkClient, "ports", portId, objectTags(request.Deployment, request.Resource))
 		}
-		// Wait 5 seconds before checking again
-		time.Sleep(5 * time.Second)
 	}
 
-	logrus.Debugf("Successfully deployed host %s as server %s (%s)", request.Resource.Key, deployedServer.Name, deployedServer.ID)
+	logrus.Debugf("Successfully deployed host %s as server %s (%s)", request.Resource.Key, finalServer.Name, finalServer.ID)
 
 	return updatedVars, nil
 }
@@ -275,21 +313,51 @@ func (provider *ProviderOpenstack) deployNetwork(ctx context.Context, authClient
 	if object.Network.Name != nil {
 		networkName = *object.Network.Name
 	}
-	// Prepend the first 8 bytes of deployment ID
-	networkName = request.Deployment.Id[:8] + "-" + networkName
+	// Generate the network name from the name template
+	networkName, err = objectName(request.Deployment, request.Resource, networkName)
+	if err != nil {
+		return nil, err
+	}
 
-	// Create the network
-	deployedNetwork, err := networks.Create(networkClient, networks.CreateOpts{
+	// Configure the network
+	var networkConfig networks.CreateOptsBuilder = networks.CreateOpts{
 		Name:         networkName,
 		AdminStateUp: gophercloud.Enabled,
-	}).Extract()
+		Shared:       object.Network.Shared,
+	}
+	// Bind the network to a physical segment if provider settings are set
+	if object.Network.Provider != nil {
+		networkConfig = networkProviderCreateOptsExt{
+			CreateOptsBuilder: networkConfig,
+			NetworkType:       object.Network.Provider.NetworkType,
+			PhysicalNetwork:   object.Network.Provider.PhysicalNetwork,
+			SegmentationID:    object.Network.Provider.SegmentationID,
+		}
+	}
+	// Mark the network as external if set
+	if object.Network.External != nil {
+		networkConfig = external.CreateOptsExt{
+			CreateOptsBuilder: networkConfig,
+			External:          object.Network.External,
+		}
+	}
+
+	// Create the network
+	deployedNetwork, err := networks.Create(networkClient, networkConfig).Extract()
 	if err != nil {
+		// Provider, shared and external settings are admin-only by default
+		if isForbidden(err) && (object.Network.Provider != nil || object.Network.Shared != nil || object.Network.External != nil) {
+			return nil, fmt.Errorf("failed to create network: provider, shared and external settings require admin credentials: %v", err)
+		}
 		return nil, fmt.Errorf("failed to create network: %v", err)
 	}
 
 	// Save the deployed network id into vars
 	updatedVars["id"] = deployedNetwork.ID
 
+	// Tag the network
+	tagNetworkObject(networkClient, "networks", deployedNetwork.ID, objectTags(request.Deployment, request.Resource))
+
 	// Configure the subnet on the network
 	var gatewayIp *string = nil
 	if object.Network.Gateway != nil {
@@ -304,9 +372,13 @@ func (provider *ProviderOpenstack) deployNetwork(ctx context.Context, authClient
 		})
 	}
 	dnsServers := []string{}
-	for _, resolverIP := range object.Network.Resolvers {
+	for _, resolverIP := range object.Network.Resolvers.ForSubnet(object.Network.Subnet) {
 		dnsServers = append(dnsServers, resolverIP.String())
 	}
+	ipVersion := gophercloud.IPv4
+	if object.Network.Subnet.Addr().Is6() {
+		ipVersion = gophercloud.IPv6
+	}
 
 	// Create openstack subnet on network
 	deployedSubnet, err := subnets.Create(networkClient, subnets.CreateOpts{
@@ -316,7 +388,7 @@ func (provider *ProviderOpenstack) deployNetwork(ctx context.Context, authClient
 		Description:     fmt.Sprintf("%s Subnet for Network \"%s\"", object.Network.Subnet.String(), networkName),
 		AllocationPools: dhcpPools,
 		GatewayIP:       gatewayIp,
-		IPVersion:       gophercloud.IPv4,
+		IPVersion:       ipVersion,
 		EnableDHCP:      gophercloud.Enabled,
 		DNSNameservers:  dnsServers,
 	}).Extract()
@@ -324,8 +396,13 @@ func (provider *ProviderOpenstack) deployNetwork(ctx context.Context, authClient
 		return nil, fmt.Errorf("failed to create subnet: %v", err)
 	}
 
-	// Save the deployed network subnet id into vars
-	updatedVars["subnet_id"] = deployedSubnet.ID
+	// Tag the subnet
+	tagNetworkObject(networkClient, "subnets", deployedSubnet.ID, objectTags(request.Deployment, request.Resource))
+
+	// Save the deployed network and subnet details into vars
+	if err = setNetworkVars(networkClient, deployedNetwork.ID, deployedSubnet, updatedVars); err != nil {
+		return nil, fmt.Errorf("failed to get network details: %v", err)
+	}
 
 	logrus.Debugf("Successfully deployed network %s as network %s (%s)", request.Resource.Key, deployedNetwork.Name, deployedNetwork.ID)
 
@@ -351,86 +428,258 @@ func (provider *ProviderOpenstack) deployRouter(ctx context.Context, authClient
 		return nil, fmt.Errorf("failed to create openstack network client: %v", err)
 	}
 
-	// Pull the external network ID from dependencyVars
-	networkVars, ok := dependencyVars[object.Router.ExternalNetwork]
-	if !ok {
-		return nil, fmt.Errorf("failed to get vars for external network %s", object.Router.ExternalNetwork)
+	// Use either key or provided name as router name
+	routerName := request.Resource.Key
+	if object.Router.Name != nil {
+		routerName = *object.Router.Name
 	}
-	externalNetworkId, exists := networkVars.Vars["id"]
-	if !exists {
-		return nil, fmt.Errorf("ID unknown for network \"%s\"", object.Router.ExternalNetwork)
+	// Generate the router name from the name template
+	routerName, err = objectName(request.Deployment, request.Resource, routerName)
+	if err != nil {
+		return nil, err
 	}
 
-	// Prepend the first 8 bytes of deployment ID
-	routerName := request.Deployment.Id[:8] + "-" + request.Resource.Key
-
 	routerConfig := routers.CreateOpts{
 		Name:         routerName,
 		Description:  "",
 		AdminStateUp: gophercloud.Enabled,
-		GatewayInfo: &routers.GatewayInfo{
-			NetworkID: externalNetworkId,
-		},
-	}
-	if object.Router.Name != nil {
-		routerConfig.Name = *object.Router.Name
+		Distributed:  object.Router.Distributed,
 	}
 	if object.Router.Description != nil {
 		routerConfig.Description = *object.Router.Description
 	}
 
+	// Configure the external gateway (omit for internal-only routers)
+	routerConfig.GatewayInfo, err = routerGatewayInfo(networkClient, object.Router, dependencyVars)
+	if err != nil {
+		return nil, err
+	}
+
 	// Deploy the router
-	deployedRouter, err := routers.Create(networkClient, routerConfig).Extract()
+	deployedRouter, err := routers.Create(networkClient, routerHACreateOptsExt{
+		CreateOptsBuilder: routerConfig,
+		HA:                object.Router.HA,
+	}).Extract()
 	if err != nil {
+		// Distributed, HA, SNAT and fixed IP settings are admin-only by default
+		if isForbidden(err) && (object.Router.Distributed != nil || object.Router.HA != nil || object.Router.EnableSNAT != nil || len(object.Router.ExternalFixedIPs) > 0) {
+			return nil, fmt.Errorf("failed to create router: distributed, ha, enable_snat and external_fixed_ips settings may require admin credentials: %v", err)
+		}
 		return nil, fmt.Errorf("failed to create router: %v", err)
 	}
 
 	// Save the deployed router into vars
 	updatedVars["id"] = deployedRouter.ID
 
+	// Tag the router
+	tagNetworkObject(networkClient, "routers", deployedRouter.ID, objectTags(request.Deployment, request.Resource))
+
 	// Connect router to all attached networks
 	for k, networkAttachment := range object.Router.Networks {
-		// Extract the network vars from dependencyVars
-		networkVars, ok := dependencyVars[k]
-		if !ok {
-			return nil, fmt.Errorf("failed to get vars for network %s", k)
+		portId, err := attachRouterNetwork(networkClient, deployedRouter.ID, k, networkAttachment, dependencyVars)
+		if err != nil {
+			return nil, err
 		}
 
-		// Get network and subnet ID's
-		networkId, exists := networkVars.Vars["id"]
-		if !exists {
-			return nil, fmt.Errorf("ID unknown for network \"%s\"", k)
+		// Save the deployed router network port into vars
+		updatedVars[k+"_port_id"] = portId
+
+		// Tag the router port
+		tagNetworkObject(networkClient, "ports", portId, objectTags(request.Deployment, request.Resource))
+	}
+
+	// Add static routes once all interfaces are attached (next hops must be reachable)
+	if len(object.Router.Routes) > 0 {
+		routes := routerRoutes(object.Router)
+		_, err = routers.Update(networkClient, deployedRouter.ID, routers.UpdateOpts{
+			Routes: &routes,
+		}).Extract()
+		if err != nil {
+			return nil, fmt.Errorf("failed to add router routes: %v", err)
 		}
-		networkSubnetId, exists := networkVars.Vars["subnet_id"]
+	}
+
+	// Map the IDs of attached networks to their keys
+	networkKeys := make(map[string]string)
+	for k := range object.Router.Networks {
+		networkKeys[dependencyVars[k].Vars["id"]] = k
+	}
+
+	// Save the router details and interface addresses into vars
+	if err = setRouterVars(networkClient, deployedRouter.ID, networkKeys, updatedVars); err != nil {
+		return nil, fmt.Errorf("failed to get router details: %v", err)
+	}
+
+	logrus.Debugf("Successfully deployed router %s as router %s (%s)", request.Resource.Key, deployedRouter.Name, deployedRouter.ID)
+
+	return updatedVars, nil
+}
+
+// routerGatewayInfo resolves the external gateway of a router (nil for internal-only routers). The
+// external network may be the key of a network in dependencyVars, or an Openstack ID or name
+func routerGatewayInfo(networkClient *gophercloud.ServiceClient, router *OpenstackRouter, dependencyVars map[string]*pgrpc.DependencyVars) (*routers.GatewayInfo, error) {
+	if router.ExternalNetwork == "" {
+		if router.EnableSNAT != nil || len(router.ExternalFixedIPs) > 0 {
+			return nil, fmt.Errorf("enable_snat and external_fixed_ips require an external network")
+		}
+		return nil, nil
+	}
+
+	var externalNetworkId string
+	if networkVars, ok := dependencyVars[router.ExternalNetwork]; ok {
+		// Pull the external network ID from dependencyVars
+		id, exists := networkVars.Vars["id"]
 		if !exists {
-			return nil, fmt.Errorf("ID unknown for network \"%s\" subnet", k)
+			return nil, fmt.Errorf("ID unknown for network \"%s\"", router.ExternalNetwork)
 		}
+		externalNetworkId = id
+	} else {
+		// Otherwise treat the external network as an Openstack ID or name
+		externalNetwork, err := resolveExternalNetwork(networkClient, router.ExternalNetwork)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve external network: %v", err)
+		}
+		externalNetworkId = externalNetwork.ID
+	}
+
+	// Resolve the requested external fixed IPs
+	externalFixedIPs, err := resolveRouterExternalFixedIPs(networkClient, externalNetworkId, router.ExternalFixedIPs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve external fixed ips: %v", err)
+	}
+
+	return &routers.GatewayInfo{
+		NetworkID:        externalNetworkId,
+		EnableSNAT:       router.EnableSNAT,
+		ExternalFixedIPs: externalFixedIPs,
+	}, nil
+}
+
+// attachRouterNetwork adds an interface to a router on the network with the given key (from
+// dependencyVars) and returns the ID of the interface port
+func attachRouterNetwork(networkClient *gophercloud.ServiceClient, routerId string, key string, networkAttachment OpenstackNetworkAttachment, dependencyVars map[string]*pgrpc.DependencyVars) (string, error) {
+	// Extract the network vars from dependencyVars
+	networkVars, ok := dependencyVars[key]
+	if !ok {
+		return "", fmt.Errorf("failed to get vars for network %s", key)
+	}
+
+	// Get network and subnet ID's
+	networkId, exists := networkVars.Vars["id"]
+	if !exists {
+		return "", fmt.Errorf("ID unknown for network \"%s\"", key)
+	}
+	networkSubnetId, exists := networkVars.Vars["subnet_id"]
+	if !exists {
+		return "", fmt.Errorf("ID unknown for network \"%s\" subnet", key)
+	}
+
+	var interfaceOpts routers.AddInterfaceOpts
+	if networkAttachment.DHCP || networkAttachment.IP != nil {
 		// Create Openstack port for router on subnet
+		portIP := ports.IP{
+			SubnetID: networkSubnetId,
+		}
+		// Let Openstack allocate an address from the subnet if using DHCP
+		if !networkAttachment.DHCP {
+			portIP.IPAddress = networkAttachment.IP.String()
+		}
 		osPort, err := ports.Create(networkClient, ports.CreateOpts{
 			NetworkID:    networkId,
 			AdminStateUp: gophercloud.Enabled,
-			FixedIPs: []ports.IP{{
-				SubnetID:  networkSubnetId,
-				IPAddress: networkAttachment.IP.String(),
-			}},
+			FixedIPs:     []ports.IP{portIP},
 		}).Extract()
 		if err != nil {
-			return nil, fmt.Errorf("failed to create port for router: %v", err)
+			return "", fmt.Errorf("failed to create port for router: %v", err)
 		}
+		interfaceOpts.PortID = osPort.ID
+	} else {
+		// Attach directly to the subnet to use the subnet gateway IP
+		interfaceOpts.SubnetID = networkSubnetId
+	}
 
-		// Save the deployed router network port into vars
-		updatedVars[k+"_port_id"] = osPort.ID
+	// Attach the router to the network
+	routerInterface, err := routers.AddInterface(networkClient, routerId, interfaceOpts).Extract()
+	if err != nil {
+		// Don't leak the port created for the interface (it isn't tagged yet, so can't be swept)
+		if interfaceOpts.PortID != "" {
+			if deleteErr := ports.Delete(networkClient, interfaceOpts.PortID).ExtractErr(); deleteErr != nil && !isNotFound(deleteErr) {
+				logrus.Warnf("failed to delete port %s of failed router interface: %v", interfaceOpts.PortID, deleteErr)
+			}
+		}
+		return "", fmt.Errorf("failed to create router interface: %v", err)
+	}
+	return routerInterface.PortID, nil
+}
 
-		// We don't need to store this ID since it will get auto-deleted on router delete
-		_, err = routers.AddInterface(networkClient, deployedRouter.ID, routers.AddInterfaceOpts{
-			PortID: osPort.ID,
-		}).Extract()
-		if err != nil {
-			return nil, fmt.Errorf("failed to create router interface: %v", err)
+// routerRoutes converts the static routes of a router to Openstack routes
+func routerRoutes(router *OpenstackRouter) []routers.Route {
+	routes := make([]routers.Route, len(router.Routes))
+	for i, route := range router.Routes {
+		routes[i] = routers.Route{
+			DestinationCIDR: route.Destination.String(),
+			NextHop:         route.NextHop.String(),
 		}
 	}
+	return routes
+}
 
-	logrus.Debugf("Successfully deployed router %s as router %s (%s)", request.Resource.Key, deployedRouter.Name, deployedRouter.ID)
+// resolveRouterExternalFixedIPs converts the requested external IPs into Openstack fixed IPs, finding
+// the external subnet containing the IP for any requests without a subnet ID
+func resolveRouterExternalFixedIPs(networkClient *gophercloud.ServiceClient, externalNetworkId string, externalIPs []OpenstackRouterExternalIP) ([]routers.ExternalFixedIP, error) {
+	fixedIPs := []routers.ExternalFixedIP{}
+	if len(externalIPs) == 0 {
+		return fixedIPs, nil
+	}
+
+	// Get all subnets on the external network (only if a lookup is needed)
+	var externalSubnets []subnets.Subnet
+	for _, externalIP := range externalIPs {
+		if externalIP.SubnetID == "" && externalIP.IP != nil && externalSubnets == nil {
+			err := subnets.List(networkClient, subnets.ListOpts{
+				NetworkID: externalNetworkId,
+			}).EachPage(func(p pagination.Page) (bool, error) {
+				s, err := subnets.ExtractSubnets(p)
+				if err != nil {
+					return false, fmt.Errorf("failed to extract subnet pages")
+				}
+				externalSubnets = append(externalSubnets, s...)
+				return true, nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to list external subnets: %v", err)
+			}
+		}
+	}
 
-	return updatedVars, nil
+	for _, externalIP := range externalIPs {
+		fixedIP := routers.ExternalFixedIP{
+			SubnetID: externalIP.SubnetID,
+		}
+		if externalIP.IP != nil {
+			fixedIP.IPAddress = externalIP.IP.String()
+		}
+		// Find the subnet which contains the requested IP
+		if fixedIP.SubnetID == "" {
+			if externalIP.IP == nil {
+				return nil, fmt.Errorf("either ip or subnet_id is required")
+			}
+			for _, subnet := range externalSubnets {
+				prefix, err := netip.ParsePrefix(subnet.CIDR)
+				if err != nil {
+					continue
+				}
+				if prefix.Contains(*externalIP.IP) {
+					fixedIP.SubnetID = subnet.ID
+					break
+				}
+			}
+			if fixedIP.SubnetID == "" {
+				return nil, fmt.Errorf("no subnet on external network contains %s", externalIP.IP)
+			}
+		}
+		fixedIPs = append(fixedIPs, fixedIP)
+	}
+
+	return fixedIPs, nil
 }
@@ -0,0 +1,39 @@
+package openstack
+
+import (
+	"net/http"
+	"testing"
+
+	pgrpc "github.com/cble-platform/cble-provider-grpc/pkg/provider"
+)
+
+func TestAttachRouterNetworkDeletesPortOnFailure(t *testing.T) {
+	deletedPorts := []string{}
+	networkClient := newTestServiceClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/ports":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"port": {"id": "port-id", "network_id": "net-id"}}`))
+		case r.Method == http.MethodPut && r.URL.Path == "/routers/router-id/add_router_interface":
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"NeutronError": {"message": "subnet already attached"}}`))
+		case r.Method == http.MethodDelete && r.URL.Path == "/ports/port-id":
+			deletedPorts = append(deletedPorts, "port-id")
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	dependencyVars := map[string]*pgrpc.DependencyVars{
+		"net1": {Vars: map[string]string{"id": "net-id", "subnet_id": "subnet-id"}},
+	}
+	_, err := attachRouterNetwork(networkClient, "router-id", "net1", OpenstackNetworkAttachment{DHCP: true}, dependencyVars)
+	if err == nil {
+		t.Fatalf("expected error when the interface can't be added")
+	}
+	if len(deletedPorts) != 1 {
+		t.Errorf("the port created for the interface wasn't deleted")
+	}
+}
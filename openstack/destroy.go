@@ -3,18 +3,19 @@ package openstack
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	pgrpc "github.com/cble-platform/cble-provider-grpc/pkg/provider"
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack"
-	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/ports"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/routers"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/subnets"
+	"github.com/gophercloud/gophercloud/pagination"
 	"github.com/sirupsen/logrus"
-	"gopkg.in/yaml.v3"
 )
 
 func (provider ProviderOpenstack) DestroyResource(ctx context.Context, request *pgrpc.DestroyResourceRequest) (*pgrpc.DestroyResourceReply, error) {
@@ -39,7 +40,7 @@ func (provider ProviderOpenstack) DestroyResource(ctx context.Context, request *
 
 	// Unmarshal the object YAML as struct
 	var object *OpenstackObject
-	err = yaml.Unmarshal(request.Resource.Object, &object)
+	object, err = parseObject(request.Resource.Key, request.Resource.Object)
 	if err != nil {
 		return &pgrpc.DestroyResourceReply{
 			Success: false,
@@ -55,39 +56,37 @@ func (provider ProviderOpenstack) DestroyResource(ctx context.Context, request *
 		}, nil
 	}
 
-	var updatedVars map[string]string
-
-	// Destroy the resource based on the type of resource
-	switch *object.Resource {
-	// HOST
-	case OpenstackResourceTypeHost:
-		// Destroy host
-		if updatedVars, err = provider.destroyHost(ctx, authClient, request, object, request.Vars); err != nil {
-			return &pgrpc.DestroyResourceReply{
-				Success: false,
-				Error:   Errorf("failed to destroy host: %v", err),
-			}, nil
-		}
-	// NETWORK
-	case OpenstackResourceTypeNetwork:
-		// Destroy network
-		if updatedVars, err = provider.destroyNetwork(ctx, authClient, request, object, request.Vars); err != nil {
-			return &pgrpc.DestroyResourceReply{
-				Success: false,
-				Error:   Errorf("failed to destroy network: %v", err),
-			}, nil
-		}
-	// ROUTER
-	case OpenstackResourceTypeRouter:
-		// Destroy router
-		if updatedVars, err = provider.destroyRouter(ctx, authClient, request, object, request.Vars); err != nil {
-			return &pgrpc.DestroyResourceReply{
-				Success: false,
-				Error:   Errorf("failed to destroy router: %v", err),
-			}, nil
-		}
+	// Convert vars stored for a superseded version of the type
+	request.Vars, err = migrateVars(object, request.Vars)
+	if err != nil {
+		return &pgrpc.DestroyResourceReply{
+			Success: false,
+			Error:   Errorf("%v", err),
+		}, nil
+	}
+
+	// Get the handler of the resource type
+	handler, err := getResourceHandler(*object.Resource)
+	if err != nil {
+		return &pgrpc.DestroyResourceReply{
+			Success: false,
+			Error:   Errorf("%v", err),
+		}, nil
+	}
+
+	// Destroy the resource
+	updatedVars, err := handler.Destroy(ctx, &provider, authClient, request, object, request.Vars)
+	if err != nil {
+		return &pgrpc.DestroyResourceReply{
+			Success: false,
+			Error:   Errorf("failed to destroy %s: %v", object.Resource.ShortName(), err),
+		}, nil
 	}
 
+	// Nothing is deployed anymore
+	delete(updatedVars, deployedObjectVar)
+	delete(updatedVars, typeVar)
+
 	// Return the updated vars
 	return &pgrpc.DestroyResourceReply{
 		Success:     true,
@@ -134,8 +133,15 @@ func (provider *ProviderOpenstack) destroyHost(ctx context.Context, authClient *
 			time.Sleep(time.Second)
 		}
 
-		// Remove server ID from the vars
-		delete(updatedVars, "id")
+		// Remove server details from the vars
+		for _, k := range hostVarKeys {
+			delete(updatedVars, k)
+		}
+		for networkKey := range object.Host.Networks {
+			for _, k := range hostNetworkVarKeys {
+				delete(updatedVars, networkKey+"_"+k)
+			}
+		}
 	}
 
 	logrus.Debugf("Successfully destroyed host %s", request.Resource.Key)
@@ -162,6 +168,26 @@ func (provider *ProviderOpenstack) destroyNetwork(ctx context.Context, authClien
 		return nil, fmt.Errorf("failed to create openstack network client: %v", err)
 	}
 
+	// Remove everything using the network first if cascade is enabled
+	if osNetworkId, ok := vars["id"]; ok && object.Network.CascadeDestroy {
+		steps, err := planNetworkCascade(networkClient, osNetworkId, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to plan cascade: %v", err)
+		}
+		if err = runNetworkCascade(networkClient, steps); err != nil {
+			return nil, fmt.Errorf("failed to cascade: %v", err)
+		}
+
+		// Remove network details from the vars
+		for _, k := range networkVarKeys {
+			delete(updatedVars, k)
+		}
+
+		logrus.Debugf("Successfully destroyed network %s (cascade)", request.Resource.Key)
+
+		return updatedVars, nil
+	}
+
 	// Get the Openstack subnet ID from vars
 	osSubnetId, ok := vars["subnet_id"]
 	if ok {
@@ -208,8 +234,10 @@ func (provider *ProviderOpenstack) destroyNetwork(ctx context.Context, authClien
 			time.Sleep(time.Second)
 		}
 
-		// Remove network ID from the vars
-		delete(updatedVars, "id")
+		// Remove network details from the vars
+		for _, k := range networkVarKeys {
+			delete(updatedVars, k)
+		}
 	}
 
 	logrus.Debugf("Successfully destroyed network %s", request.Resource.Key)
@@ -240,58 +268,132 @@ func (provider *ProviderOpenstack) destroyRouter(ctx context.Context, authClient
 	osRouterId, ok := vars["id"]
 	if ok {
 		// Delete the router if exists
+		_, err = routers.Get(networkClient, osRouterId).Extract()
+		if err != nil && !isNotFound(err) {
+			return nil, fmt.Errorf("failed to get router: %v", err)
+		}
+		routerExists := err == nil
+
+		if routerExists {
+			// Remove all routes (next hops must stay reachable while routes exist)
+			_, err = routers.Update(networkClient, osRouterId, routers.UpdateOpts{
+				Routes: &[]routers.Route{},
+			}).Extract()
+			if err != nil {
+				return nil, fmt.Errorf("failed to clear router routes: %v", err)
+			}
+		}
 
 		// Remove router from every network (delete all ports)
 		for k := range object.Router.Networks {
 			// Get the Openstack router port ID (for this network) from vars
 			osPortId, ok := vars[k+"_port_id"]
 			if ok {
-				// Delete the router port if exists
+				if err = detachRouterNetwork(networkClient, osRouterId, osPortId, routerExists); err != nil {
+					return nil, err
+				}
+
+				// Remove router port ID and address from the vars
+				delete(updatedVars, k+"_port_id")
+				delete(updatedVars, k+"_ip")
+			}
+		}
+
+		if routerExists {
+			// Remove any interfaces not tracked in vars (otherwise the router can't be deleted)
+			routerPorts := []ports.Port{}
+			err = ports.List(networkClient, ports.ListOpts{
+				DeviceID: osRouterId,
+			}).EachPage(func(p pagination.Page) (bool, error) {
+				pp, err := ports.ExtractPorts(p)
+				if err != nil {
+					return false, fmt.Errorf("failed to extract port pages")
+				}
+				routerPorts = append(routerPorts, pp...)
+				return true, nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to list router ports: %v", err)
+			}
+			for _, port := range routerPorts {
+				if !isRouterInterfacePort(port.DeviceOwner) {
+					continue
+				}
 				_, err = routers.RemoveInterface(networkClient, osRouterId, routers.RemoveInterfaceOpts{
-					PortID: osPortId,
+					PortID: port.ID,
 				}).Extract()
-				if err != nil {
-					return nil, fmt.Errorf("failed to delete router port: %v", err)
+				if err != nil && !isNotFound(err) {
+					return nil, fmt.Errorf("failed to delete router port %s: %v", port.ID, err)
 				}
-
-				// Wait for the router port to be fully deleted
-				for {
-					_, err = ports.Get(networkClient, osPortId).Extract()
-					if err != nil {
-						// Router port is deleted
-						break
-					}
-					// Check every second
-					time.Sleep(time.Second)
+				if err = waitForPortDeletion(networkClient, port.ID); err != nil {
+					return nil, fmt.Errorf("failed to delete router port %s: %v", port.ID, err)
 				}
+			}
 
-				// Remove router port ID from the vars
-				delete(updatedVars, k+"_port_id")
+			// Clear the external gateway
+			_, err = routers.Update(networkClient, osRouterId, routers.UpdateOpts{
+				GatewayInfo: &routers.GatewayInfo{},
+			}).Extract()
+			if err != nil && !isNotFound(err) {
+				return nil, fmt.Errorf("failed to clear router gateway: %v", err)
 			}
-		}
 
-		// Delete the Openstack router
-		err = routers.Delete(networkClient, osRouterId).ExtractErr()
-		if err != nil {
-			return nil, fmt.Errorf("failed to delete network: %v", err)
-		}
+			// Delete the Openstack router
+			err = routers.Delete(networkClient, osRouterId).ExtractErr()
+			if err != nil && !isNotFound(err) {
+				return nil, fmt.Errorf("failed to delete router: %v", err)
+			}
 
-		// Wait for the router to be fully deleted
-		for {
-			_, err = routers.Get(networkClient, osRouterId).Extract()
+			// Wait for the router to be fully deleted
+			err = waitForDeletion(func() error {
+				_, err := routers.Get(networkClient, osRouterId).Extract()
+				return err
+			})
 			if err != nil {
-				// Router is deleted
-				break
+				return nil, fmt.Errorf("failed to delete router: %v", err)
 			}
-			// Check every second
-			time.Sleep(time.Second)
 		}
 
-		// Remove router ID from the vars
-		delete(updatedVars, "id")
+		// Remove router details from the vars
+		for _, k := range routerVarKeys {
+			delete(updatedVars, k)
+		}
+		for k := range updatedVars {
+			if strings.HasPrefix(k, "route_") {
+				delete(updatedVars, k)
+			}
+		}
 	}
 
 	logrus.Debugf("Successfully destroyed router %s", request.Resource.Key)
 
 	return updatedVars, nil
 }
+
+// detachRouterNetwork removes an interface port from a router (if the router still exists) and waits
+// for the port to be deleted
+func detachRouterNetwork(networkClient *gophercloud.ServiceClient, routerId string, portId string, routerExists bool) error {
+	if routerExists {
+		// Delete the router port if exists
+		_, err := routers.RemoveInterface(networkClient, routerId, routers.RemoveInterfaceOpts{
+			PortID: portId,
+		}).Extract()
+		if err != nil && !isNotFound(err) {
+			return fmt.Errorf("failed to delete router port: %v", err)
+		}
+	}
+
+	// Wait for the router port to be fully deleted
+	if err := waitForPortDeletion(networkClient, portId); err != nil {
+		return fmt.Errorf("failed to delete router port: %v", err)
+	}
+	return nil
+}
+
+// waitForPortDeletion waits until the Neutron port no longer exists
+func waitForPortDeletion(networkClient *gophercloud.ServiceClient, portId string) error {
+	return waitForDeletion(func() error {
+		_, err := ports.Get(networkClient, portId).Extract()
+		return err
+	})
+}
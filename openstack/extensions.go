@@ -0,0 +1,66 @@
+package openstack
+
+import (
+	"fmt"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/routers"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
+)
+
+// networkProviderCreateOptsExt adds the single-segment provider network
+// attributes (provider:network_type, etc.) to the base network create options
+type networkProviderCreateOptsExt struct {
+	networks.CreateOptsBuilder
+	NetworkType     string
+	PhysicalNetwork string
+	SegmentationID  *int
+}
+
+func (opts networkProviderCreateOptsExt) ToNetworkCreateMap() (map[string]interface{}, error) {
+	base, err := opts.CreateOptsBuilder.ToNetworkCreateMap()
+	if err != nil {
+		return nil, err
+	}
+
+	networkMap, ok := base["network"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("network create options have no network map")
+	}
+	if opts.NetworkType != "" {
+		networkMap["provider:network_type"] = opts.NetworkType
+	}
+	if opts.PhysicalNetwork != "" {
+		networkMap["provider:physical_network"] = opts.PhysicalNetwork
+	}
+	if opts.SegmentationID != nil {
+		networkMap["provider:segmentation_id"] = *opts.SegmentationID
+	}
+
+	return base, nil
+}
+
+// routerHACreateOptsExt adds the L3 HA attribute to the base router create
+// options (not supported by gophercloud routers.CreateOpts)
+type routerHACreateOptsExt struct {
+	routers.CreateOptsBuilder
+	HA *bool
+}
+
+func (opts routerHACreateOptsExt) ToRouterCreateMap() (map[string]interface{}, error) {
+	base, err := opts.CreateOptsBuilder.ToRouterCreateMap()
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.HA == nil {
+		return base, nil
+	}
+
+	routerMap, ok := base["router"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("router create options have no router map")
+	}
+	routerMap["ha"] = *opts.HA
+
+	return base, nil
+}
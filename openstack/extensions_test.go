@@ -0,0 +1,68 @@
+package openstack
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/routers"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
+)
+
+// badNetworkCreateOpts builds create options without a network map
+type badNetworkCreateOpts struct{}
+
+func (badNetworkCreateOpts) ToNetworkCreateMap() (map[string]interface{}, error) {
+	return map[string]interface{}{"network": "not a map"}, nil
+}
+
+func TestNetworkProviderCreateOptsExt(t *testing.T) {
+	segmentationId := 100
+	got, err := networkProviderCreateOptsExt{
+		CreateOptsBuilder: networks.CreateOpts{Name: "lab"},
+		NetworkType:       "vlan",
+		PhysicalNetwork:   "physnet1",
+		SegmentationID:    &segmentationId,
+	}.ToNetworkCreateMap()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]interface{}{"network": map[string]interface{}{
+		"name":                      "lab",
+		"provider:network_type":     "vlan",
+		"provider:physical_network": "physnet1",
+		"provider:segmentation_id":  100,
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err := (networkProviderCreateOptsExt{CreateOptsBuilder: badNetworkCreateOpts{}}).ToNetworkCreateMap(); err == nil {
+		t.Errorf("expected error for create options without a network map")
+	}
+}
+
+// badRouterCreateOpts builds create options without a router map
+type badRouterCreateOpts struct{}
+
+func (badRouterCreateOpts) ToRouterCreateMap() (map[string]interface{}, error) {
+	return map[string]interface{}{"router": "not a map"}, nil
+}
+
+func TestRouterHACreateOptsExt(t *testing.T) {
+	ha := true
+	got, err := routerHACreateOptsExt{
+		CreateOptsBuilder: routers.CreateOpts{Name: "edge"},
+		HA:                &ha,
+	}.ToRouterCreateMap()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]interface{}{"router": map[string]interface{}{"name": "edge", "ha": true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err := (routerHACreateOptsExt{CreateOptsBuilder: badRouterCreateOpts{}, HA: &ha}).ToRouterCreateMap(); err == nil {
+		t.Errorf("expected error for create options without a router map")
+	}
+}
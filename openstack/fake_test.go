@@ -0,0 +1,75 @@
+package openstack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gophercloud/gophercloud"
+)
+
+// newTestServiceClient creates a service client for a fake Openstack service
+func newTestServiceClient(t *testing.T, handler http.HandlerFunc) *gophercloud.ServiceClient {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return &gophercloud.ServiceClient{
+		ProviderClient: &gophercloud.ProviderClient{},
+		Endpoint:       server.URL + "/",
+	}
+}
+
+// withFakeIdentity configures the provider against a fake Keystone which issues tokens (with an
+// empty catalog, scoped to test-project-id) for the duration of a test
+func withFakeIdentity(t *testing.T) {
+	t.Helper()
+	withFakeCloud(t, nil)
+}
+
+// withFakeCloud configures the provider against a fake cloud for the duration of a test. Keystone issues
+// tokens (scoped to test-project-id) whose catalog has compute, volume and network endpoints at
+// /compute/, /volume/ and /network/, and every other request is passed to the services handler (if set)
+func withFakeCloud(t *testing.T, services http.HandlerFunc) {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/auth/tokens" {
+			if services == nil {
+				http.NotFound(w, r)
+				return
+			}
+			services(w, r)
+			return
+		}
+		catalog := "[]"
+		if services != nil {
+			endpoint := func(serviceType string, name string, path string) string {
+				return `{"type": "` + serviceType + `", "name": "` + name + `", "endpoints": [{"interface": "public", "region": "", "region_id": "", "url": "` + server.URL + path + `"}]}`
+			}
+			catalog = "[" + strings.Join([]string{
+				endpoint("compute", "nova", "/compute/"),
+				endpoint("volumev3", "cinderv3", "/volume/"),
+				endpoint("network", "neutron", "/network/"),
+			}, ", ") + "]"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Subject-Token", "test-token")
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+		}
+		w.Write([]byte(`{"token": {"expires_at": "2099-01-01T00:00:00.000000Z", "catalog": ` + catalog + `, "project": {"id": "test-project-id", "name": "test"}}}`))
+	}))
+	oldConfig := CONFIG
+	CONFIG = &ProviderOpenstackConfig{
+		AuthUrl:     server.URL + "/v3/",
+		Username:    "test",
+		Password:    "test",
+		ProjectName: "test",
+		DomainName:  "Default",
+	}
+	t.Cleanup(func() {
+		CONFIG = oldConfig
+		server.Close()
+	})
+}
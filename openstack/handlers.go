@@ -0,0 +1,406 @@
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	pgrpc "github.com/cble-platform/cble-provider-grpc/pkg/provider"
+	"github.com/gophercloud/gophercloud"
+	"gopkg.in/yaml.v3"
+)
+
+// ResourceHandler implements a blueprint object type. Every RPC looks up the handler of the object
+// type in the registry, so adding a type only means writing a handler and registering it
+type ResourceHandler interface {
+	// The struct the config of the type decodes into
+	ConfigType() reflect.Type
+	// Can the type only be used as data
+	DataOnly() bool
+	// Decode the config of an object into its typed value on the object
+	Decode(config *yaml.Node, object *OpenstackObject) error
+	// Check an object of the type within the whole blueprint
+	Validate(blueprint *OpenstackBlueprint, key string) []error
+	// Fill in the dependencies and features of a resource
+	Metadata(resourceMap map[string]*pgrpc.Resource, key string, object *OpenstackObject, metadata *pgrpc.Metadata) error
+	// Calculate the quota used by a resource
+	Quota(computeClient *gophercloud.ServiceClient, resourceMap map[string]*pgrpc.Resource, object *OpenstackObject) (*OpenstackQuotaRequirements, error)
+	// Deploy a resource
+	Deploy(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.DeployResourceRequest, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error)
+	// Apply the changes between the deployed object and the new object of a deployed resource
+	// (returning an error wrapping ErrReplaceRequired if the resource has to be replaced instead)
+	Update(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.DeployResourceRequest, deployedObject *OpenstackObject, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error)
+	// Destroy a resource
+	Destroy(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.DestroyResourceRequest, object *OpenstackObject, vars map[string]string) (map[string]string, error)
+	// Get the current vars of a deployed resource from Openstack (returning ErrResourceMissing if
+	// the resource no longer exists)
+	Refresh(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, resource *pgrpc.Resource, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error)
+	// Retrieve a data object
+	Retrieve(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.RetrieveDataRequest, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error)
+	// Change the power state of a resource
+	Power(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.ResourcePowerRequest, object *OpenstackObject) error
+	// Get a console URL for a resource
+	Console(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.GetConsoleRequest, object *OpenstackObject) (string, error)
+}
+
+// The handler of every blueprint object type
+var resourceHandlers = map[OpenstackResourceType]ResourceHandler{}
+
+func init() {
+	RegisterResourceHandler(OpenstackResourceTypeHost, hostHandler{})
+	RegisterResourceHandler(OpenstackResourceTypeNetwork, networkHandler{})
+	RegisterResourceHandler(OpenstackResourceTypeRouter, routerHandler{})
+	RegisterResourceHandler(OpenstackResourceTypeImage, imageHandler{})
+	RegisterResourceHandler(OpenstackResourceTypeFlavor, flavorHandler{})
+	RegisterResourceHandler(OpenstackResourceTypeAvailabilityZone, availabilityZoneHandler{})
+	RegisterResourceHandler(OpenstackResourceTypeSecurityGroup, securityGroupHandler{})
+}
+
+// RegisterResourceHandler adds (or replaces) the handler of a blueprint object type
+func RegisterResourceHandler(t OpenstackResourceType, handler ResourceHandler) {
+	resourceHandlers[t] = handler
+}
+
+// getResourceHandler gets the handler of a blueprint object type
+func getResourceHandler(t OpenstackResourceType) (ResourceHandler, error) {
+	handler, ok := resourceHandlers[t]
+	if !ok {
+		return nil, fmt.Errorf("unknown resource type \"%s\"", t)
+	}
+	return handler, nil
+}
+
+// registeredResourceTypes returns every registered object type in order
+func registeredResourceTypes() []OpenstackResourceType {
+	types := make([]OpenstackResourceType, 0, len(resourceHandlers))
+	for t := range resourceHandlers {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i] < types[j]
+	})
+	return types
+}
+
+// baseResourceHandler implements the operations a type doesn't support (embed it in handlers)
+type baseResourceHandler struct{}
+
+func (baseResourceHandler) DataOnly() bool {
+	return false
+}
+
+func (baseResourceHandler) Validate(blueprint *OpenstackBlueprint, key string) []error {
+	return nil
+}
+
+func (baseResourceHandler) Metadata(resourceMap map[string]*pgrpc.Resource, key string, object *OpenstackObject, metadata *pgrpc.Metadata) error {
+	metadata.Features = &pgrpc.Features{
+		Power:   false,
+		Console: false,
+	}
+	return nil
+}
+
+func (baseResourceHandler) Quota(computeClient *gophercloud.ServiceClient, resourceMap map[string]*pgrpc.Resource, object *OpenstackObject) (*OpenstackQuotaRequirements, error) {
+	return &OpenstackQuotaRequirements{}, nil
+}
+
+func (baseResourceHandler) Deploy(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.DeployResourceRequest, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	return nil, fmt.Errorf("cannot deploy this type of resource")
+}
+
+func (baseResourceHandler) Update(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.DeployResourceRequest, deployedObject *OpenstackObject, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	return nil, replaceRequired("this type of resource can't be updated in place")
+}
+
+func (baseResourceHandler) Destroy(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.DestroyResourceRequest, object *OpenstackObject, vars map[string]string) (map[string]string, error) {
+	return nil, fmt.Errorf("cannot destroy this type of resource")
+}
+
+func (baseResourceHandler) Refresh(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, resource *pgrpc.Resource, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	return nil, fmt.Errorf("cannot refresh this type of resource")
+}
+
+func (baseResourceHandler) Retrieve(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.RetrieveDataRequest, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	return nil, fmt.Errorf("cannot retrieve data for this type")
+}
+
+func (baseResourceHandler) Power(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.ResourcePowerRequest, object *OpenstackObject) error {
+	return fmt.Errorf("cannot modify power state for this resource")
+}
+
+func (baseResourceHandler) Console(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.GetConsoleRequest, object *OpenstackObject) (string, error) {
+	return "", fmt.Errorf("cannot get a console for this resource")
+}
+
+// hostHandler implements hosts (Nova servers)
+type hostHandler struct {
+	baseResourceHandler
+}
+
+func (hostHandler) ConfigType() reflect.Type {
+	return reflect.TypeOf(OpenstackHost{})
+}
+
+func (hostHandler) Decode(config *yaml.Node, object *OpenstackObject) error {
+	object.Host = new(OpenstackHost)
+	return decodeNode(config, "config", object.Host)
+}
+
+func (hostHandler) Validate(blueprint *OpenstackBlueprint, key string) []error {
+	// Host data is only a lookup
+	if blueprint.Objects[key].Data != nil {
+		return nil
+	}
+	return validateHost(blueprint, key)
+}
+
+func (hostHandler) Metadata(resourceMap map[string]*pgrpc.Resource, key string, object *OpenstackObject, metadata *pgrpc.Metadata) error {
+	return hostMetadata(resourceMap, key, object, metadata)
+}
+
+func (hostHandler) Quota(computeClient *gophercloud.ServiceClient, resourceMap map[string]*pgrpc.Resource, object *OpenstackObject) (*OpenstackQuotaRequirements, error) {
+	hostFlavor, err := resolveHostFlavor(computeClient, resourceMap, object.Host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get flavor \"%s\": %v", object.Host.Flavor, err)
+	}
+	return hostQuotaRequirements(object.Host, hostFlavor), nil
+}
+
+func (hostHandler) Deploy(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.DeployResourceRequest, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	return provider.deployHost(ctx, authClient, request, object, vars, dependencyVars)
+}
+
+func (hostHandler) Update(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.DeployResourceRequest, deployedObject *OpenstackObject, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	return provider.updateHost(ctx, authClient, request, deployedObject, object, vars, dependencyVars)
+}
+
+func (hostHandler) Destroy(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.DestroyResourceRequest, object *OpenstackObject, vars map[string]string) (map[string]string, error) {
+	return provider.destroyHost(ctx, authClient, request, object, vars)
+}
+
+func (hostHandler) Refresh(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, resource *pgrpc.Resource, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	return provider.refreshHost(ctx, authClient, resource, object, vars, dependencyVars)
+}
+
+func (hostHandler) Retrieve(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.RetrieveDataRequest, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	return provider.retrieveHostData(ctx, authClient, request, object, vars, dependencyVars)
+}
+
+func (hostHandler) Power(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.ResourcePowerRequest, object *OpenstackObject) error {
+	switch request.State {
+	case pgrpc.PowerState_ON:
+		return provider.powerOnResource(ctx, authClient, request, object)
+	case pgrpc.PowerState_OFF:
+		return provider.powerOffResource(ctx, authClient, request, object)
+	case pgrpc.PowerState_RESET:
+		return provider.resetResource(ctx, authClient, request, object)
+	default:
+		return fmt.Errorf("power state \"%s\" is unknown", request.State)
+	}
+}
+
+func (hostHandler) Console(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.GetConsoleRequest, object *OpenstackObject) (string, error) {
+	return provider.getHostConsole(ctx, authClient, request, object)
+}
+
+// networkHandler implements networks (Neutron networks with a single subnet)
+type networkHandler struct {
+	baseResourceHandler
+}
+
+func (networkHandler) ConfigType() reflect.Type {
+	return reflect.TypeOf(OpenstackNetwork{})
+}
+
+func (networkHandler) Decode(config *yaml.Node, object *OpenstackObject) error {
+	object.Network = new(OpenstackNetwork)
+	return decodeNode(config, "config", object.Network)
+}
+
+func (networkHandler) Validate(blueprint *OpenstackBlueprint, key string) []error {
+	// Network data is only a lookup
+	if blueprint.Objects[key].Data != nil {
+		return nil
+	}
+	return validateNetwork(blueprint, key)
+}
+
+func (networkHandler) Quota(computeClient *gophercloud.ServiceClient, resourceMap map[string]*pgrpc.Resource, object *OpenstackObject) (*OpenstackQuotaRequirements, error) {
+	return networkQuotaRequirements(object.Network), nil
+}
+
+func (networkHandler) Deploy(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.DeployResourceRequest, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	return provider.deployNetwork(ctx, authClient, request, object, vars, dependencyVars)
+}
+
+func (networkHandler) Update(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.DeployResourceRequest, deployedObject *OpenstackObject, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	return provider.updateNetwork(ctx, authClient, request, deployedObject, object, vars, dependencyVars)
+}
+
+func (networkHandler) Destroy(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.DestroyResourceRequest, object *OpenstackObject, vars map[string]string) (map[string]string, error) {
+	return provider.destroyNetwork(ctx, authClient, request, object, vars)
+}
+
+func (networkHandler) Refresh(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, resource *pgrpc.Resource, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	return provider.refreshNetwork(ctx, authClient, resource, object, vars, dependencyVars)
+}
+
+func (networkHandler) Retrieve(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.RetrieveDataRequest, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	return provider.retrieveNetworkData(ctx, authClient, request, object, vars, dependencyVars)
+}
+
+// routerHandler implements routers (Neutron routers)
+type routerHandler struct {
+	baseResourceHandler
+}
+
+func (routerHandler) ConfigType() reflect.Type {
+	return reflect.TypeOf(OpenstackRouter{})
+}
+
+func (routerHandler) Decode(config *yaml.Node, object *OpenstackObject) error {
+	object.Router = new(OpenstackRouter)
+	return decodeNode(config, "config", object.Router)
+}
+
+func (routerHandler) Validate(blueprint *OpenstackBlueprint, key string) []error {
+	// Router data is only a lookup
+	if blueprint.Objects[key].Data != nil {
+		return nil
+	}
+	return validateRouter(blueprint, key)
+}
+
+func (routerHandler) Metadata(resourceMap map[string]*pgrpc.Resource, key string, object *OpenstackObject, metadata *pgrpc.Metadata) error {
+	return routerMetadata(resourceMap, key, object, metadata)
+}
+
+func (routerHandler) Quota(computeClient *gophercloud.ServiceClient, resourceMap map[string]*pgrpc.Resource, object *OpenstackObject) (*OpenstackQuotaRequirements, error) {
+	return routerQuotaRequirements(object.Router), nil
+}
+
+func (routerHandler) Deploy(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.DeployResourceRequest, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	return provider.deployRouter(ctx, authClient, request, object, vars, dependencyVars)
+}
+
+func (routerHandler) Update(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.DeployResourceRequest, deployedObject *OpenstackObject, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	return provider.updateRouter(ctx, authClient, request, deployedObject, object, vars, dependencyVars)
+}
+
+func (routerHandler) Destroy(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.DestroyResourceRequest, object *OpenstackObject, vars map[string]string) (map[string]string, error) {
+	return provider.destroyRouter(ctx, authClient, request, object, vars)
+}
+
+func (routerHandler) Refresh(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, resource *pgrpc.Resource, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	return provider.refreshRouter(ctx, authClient, resource, object, vars, dependencyVars)
+}
+
+func (routerHandler) Retrieve(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.RetrieveDataRequest, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	return provider.retrieveRouterData(ctx, authClient, request, object, vars, dependencyVars)
+}
+
+// imageHandler implements image data (Glance images)
+type imageHandler struct {
+	baseResourceHandler
+}
+
+func (imageHandler) ConfigType() reflect.Type {
+	return reflect.TypeOf(OpenstackImage{})
+}
+
+func (imageHandler) DataOnly() bool {
+	return true
+}
+
+func (imageHandler) Decode(config *yaml.Node, object *OpenstackObject) error {
+	object.Image = new(OpenstackImage)
+	return decodeNode(config, "config", object.Image)
+}
+
+func (imageHandler) Validate(blueprint *OpenstackBlueprint, key string) []error {
+	return validateImage(blueprint, key)
+}
+
+func (imageHandler) Retrieve(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.RetrieveDataRequest, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	return provider.retrieveImageData(ctx, authClient, request, object, vars, dependencyVars)
+}
+
+// flavorHandler implements flavor data (Nova flavors)
+type flavorHandler struct {
+	baseResourceHandler
+}
+
+func (flavorHandler) ConfigType() reflect.Type {
+	return reflect.TypeOf(OpenstackFlavor{})
+}
+
+func (flavorHandler) DataOnly() bool {
+	return true
+}
+
+func (flavorHandler) Decode(config *yaml.Node, object *OpenstackObject) error {
+	object.Flavor = new(OpenstackFlavor)
+	return decodeNode(config, "config", object.Flavor)
+}
+
+func (flavorHandler) Validate(blueprint *OpenstackBlueprint, key string) []error {
+	return validateFlavor(blueprint, key)
+}
+
+func (flavorHandler) Retrieve(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.RetrieveDataRequest, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	return provider.retrieveFlavorData(ctx, authClient, request, object, vars, dependencyVars)
+}
+
+// availabilityZoneHandler implements availability zone data (Nova availability zones)
+type availabilityZoneHandler struct {
+	baseResourceHandler
+}
+
+func (availabilityZoneHandler) ConfigType() reflect.Type {
+	return reflect.TypeOf(OpenstackAvailabilityZone{})
+}
+
+func (availabilityZoneHandler) DataOnly() bool {
+	return true
+}
+
+func (availabilityZoneHandler) Decode(config *yaml.Node, object *OpenstackObject) error {
+	object.AvailabilityZone = new(OpenstackAvailabilityZone)
+	return decodeNode(config, "config", object.AvailabilityZone)
+}
+
+func (availabilityZoneHandler) Validate(blueprint *OpenstackBlueprint, key string) []error {
+	return validateAvailabilityZone(blueprint, key)
+}
+
+func (availabilityZoneHandler) Retrieve(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.RetrieveDataRequest, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	return provider.retrieveAvailabilityZoneData(ctx, authClient, request, object, vars, dependencyVars)
+}
+
+// securityGroupHandler implements security group data (Neutron security groups)
+type securityGroupHandler struct {
+	baseResourceHandler
+}
+
+func (securityGroupHandler) ConfigType() reflect.Type {
+	return reflect.TypeOf(OpenstackSecurityGroup{})
+}
+
+func (securityGroupHandler) DataOnly() bool {
+	return true
+}
+
+func (securityGroupHandler) Decode(config *yaml.Node, object *OpenstackObject) error {
+	object.SecurityGroup = new(OpenstackSecurityGroup)
+	return decodeNode(config, "config", object.SecurityGroup)
+}
+
+func (securityGroupHandler) Validate(blueprint *OpenstackBlueprint, key string) []error {
+	return validateSecurityGroup(blueprint, key)
+}
+
+func (securityGroupHandler) Retrieve(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.RetrieveDataRequest, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	return provider.retrieveSecurityGroupData(ctx, authClient, request, object, vars, dependencyVars)
+}
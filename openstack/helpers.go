@@ -1,10 +1,18 @@
 package openstack
 
 import (
+	"errors"
 	"fmt"
+	"net/netip"
+	"strings"
+	"time"
 
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/tokens"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/external"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
+	"github.com/gophercloud/gophercloud/pagination"
 )
 
 func (provider ProviderOpenstack) newAuthClient() (*gophercloud.ProviderClient, error) {
@@ -23,7 +31,134 @@ func (provider ProviderOpenstack) newAuthClient() (*gophercloud.ProviderClient,
 	return openstack.AuthenticatedClient(authOpts)
 }
 
+// withComputeMicroversion returns a copy of the compute client using the microversion (e.g. 2.26 to filter
+// servers by tag), leaving the original client on the default microversion
+func withComputeMicroversion(computeClient *gophercloud.ServiceClient, microversion string) *gophercloud.ServiceClient {
+	client := *computeClient
+	client.Microversion = microversion
+	return &client
+}
+
+// projectID returns the configured project ID, or the project the token is scoped to if only the project
+// name is configured
+func projectID(authClient *gophercloud.ProviderClient) (string, error) {
+	if CONFIG.ProjectID != "" {
+		return CONFIG.ProjectID, nil
+	}
+
+	// Generate the Identity V3 client
+	identityClient, err := openstack.NewIdentityV3(authClient, gophercloud.EndpointOpts{})
+	if err != nil {
+		return "", fmt.Errorf("failed to create identity client: %v", err)
+	}
+
+	// Get the project of the token
+	project, err := tokens.Get(identityClient, authClient.Token()).ExtractProject()
+	if err != nil {
+		return "", fmt.Errorf("failed to get token project: %v", err)
+	}
+	if project == nil || project.ID == "" {
+		return "", fmt.Errorf("token is not scoped to a project")
+	}
+	return project.ID, nil
+}
+
 func Errorf(format string, a ...any) *string {
 	err := fmt.Errorf(format, a...).Error()
 	return &err
 }
+
+// isForbidden checks if an Openstack error was caused by a lack of permissions (403)
+func isForbidden(err error) bool {
+	var forbidden gophercloud.ErrDefault403
+	return errors.As(err, &forbidden)
+}
+
+// isNotFound checks if an Openstack error was caused by a missing object (404)
+func isNotFound(err error) bool {
+	var notFound gophercloud.ErrDefault404
+	return errors.As(err, &notFound)
+}
+
+// ambiguousMatchError is returned when a lookup matches more than one Openstack object
+func ambiguousMatchError(objectType string, candidates []string) error {
+	return fmt.Errorf("%d %ss match, add filters to select one of: %s", len(candidates), objectType, strings.Join(candidates, ", "))
+}
+
+// resolveExternalNetwork finds the external (router:external) Openstack network with the given ID or name
+func resolveExternalNetwork(networkClient *gophercloud.ServiceClient, nameOrId string) (*networks.Network, error) {
+	matches := []networks.Network{}
+	err := networks.List(networkClient, external.ListOptsExt{
+		ListOptsBuilder: networks.ListOpts{},
+		External:        gophercloud.Enabled,
+	}).EachPage(func(p pagination.Page) (bool, error) {
+		n, err := networks.ExtractNetworks(p)
+		if err != nil {
+			return false, fmt.Errorf("failed to extract network pages")
+		}
+		for _, network := range n {
+			// An ID match is always exact
+			if network.ID == nameOrId {
+				matches = []networks.Network{network}
+				return false, nil
+			}
+			if network.Name == nameOrId {
+				matches = append(matches, network)
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list external networks: %v", err)
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("external network \"%s\" not found", nameOrId)
+	case 1:
+		return &matches[0], nil
+	default:
+		ids := make([]string, len(matches))
+		for i, network := range matches {
+			ids[i] = network.ID
+		}
+		return nil, fmt.Errorf("external network name \"%s\" is ambiguous (matches %s), use the ID instead", nameOrId, strings.Join(ids, ", "))
+	}
+}
+
+// waitForDeletion polls get until it reports the object is not found, fails or times out
+func waitForDeletion(get func() error) error {
+	for i := 0; i < 300; i++ {
+		err := get()
+		if isNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get deletion status: %v", err)
+		}
+		// Check every second
+		time.Sleep(time.Second)
+	}
+	return fmt.Errorf("timed out waiting for deletion")
+}
+
+// stringValue returns the value of an optional string (empty if unset)
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// boolValue returns the value of an optional bool (false if unset)
+func boolValue(b *bool) bool {
+	return b != nil && *b
+}
+
+// addrEqual checks if two optional IP addresses are the same
+func addrEqual(a *netip.Addr, b *netip.Addr) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,60 @@
+package openstack
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gophercloud/gophercloud"
+)
+
+func TestWaitForDeletion(t *testing.T) {
+	// Polling stops once the object is not found
+	calls := 0
+	err := waitForDeletion(func() error {
+		calls++
+		if calls < 2 {
+			return nil
+		}
+		return gophercloud.ErrDefault404{}
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("got %v after %d calls, want nil after 2", err, calls)
+	}
+
+	// Other errors don't mean the object is deleted
+	err = waitForDeletion(func() error {
+		return errors.New("service unavailable")
+	})
+	if err == nil {
+		t.Errorf("expected error when the status can't be fetched")
+	}
+}
+
+func TestProjectID(t *testing.T) {
+	withFakeIdentity(t)
+	authClient, err := ProviderOpenstack{}.newAuthClient()
+	if err != nil {
+		t.Fatalf("failed to authenticate: %v", err)
+	}
+
+	// The project of the token is used when only the project name is configured
+	if id, err := projectID(authClient); err != nil || id != "test-project-id" {
+		t.Errorf("got %q, %v, want the token project", id, err)
+	}
+
+	CONFIG.ProjectID = "configured-project-id"
+	if id, err := projectID(authClient); err != nil || id != "configured-project-id" {
+		t.Errorf("got %q, %v, want the configured project", id, err)
+	}
+}
+
+func TestWithComputeMicroversion(t *testing.T) {
+	computeClient := &gophercloud.ServiceClient{Endpoint: "http://compute/"}
+	client := withComputeMicroversion(computeClient, "2.26")
+	if client.Microversion != "2.26" || client.Endpoint != computeClient.Endpoint {
+		t.Errorf("got microversion %q at %s", client.Microversion, client.Endpoint)
+	}
+	if computeClient.Microversion != "" {
+		t.Errorf("the shared client was changed to microversion %q", computeClient.Microversion)
+	}
+}
@@ -2,13 +2,12 @@ package openstack
 
 import (
 	"context"
+	"fmt"
 
 	pgrpc "github.com/cble-platform/cble-provider-grpc/pkg/provider"
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack"
-	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
 	"github.com/sirupsen/logrus"
-	"gopkg.in/yaml.v3"
 )
 
 func extractResourceMetadataErrorReply(format string, a ...any) *pgrpc.ExtractResourceMetadataReply {
@@ -47,6 +46,18 @@ func (provider ProviderOpenstack) ExtractResourceMetadata(ctx context.Context, r
 		resourceMap[resource.Key] = resource
 	}
 
+	// Validate the whole blueprint before generating any metadata
+	blueprint, err := blueprintFromResources(request.Resources)
+	if err != nil {
+		return extractResourceMetadataErrorReply("%v", err), nil
+	}
+	if err := provider.validateBlueprintWithCatalog(authClient, blueprint); err != nil {
+		return extractResourceMetadataErrorReply("invalid blueprint: %v", err), nil
+	}
+
+	// Total quota requirements of the deployment
+	totalQuota := &OpenstackQuotaRequirements{}
+
 	// Initialize empty reply
 	reply := &pgrpc.ExtractResourceMetadataReply{
 		Success:  true,
@@ -63,108 +74,31 @@ func (provider ProviderOpenstack) ExtractResourceMetadata(ctx context.Context, r
 		}
 
 		// Unmarshal the object YAML as struct
-		var object OpenstackObject
-		err := yaml.Unmarshal(resource.Object, &object)
+		object, err := parseObject(resource.Key, resource.Object)
 		if err != nil {
-			return extractResourceMetadataErrorReply("failed to marshal object for resource %s: %v", resource.Key, err), nil
+			return extractResourceMetadataErrorReply("failed to parse object: %v", err), nil
 		}
 
 		// Only generate metadata for resource (not needed for data)
 		if object.Resource != nil {
-			// Generate metadata based on type
-			switch *object.Resource {
-			// HOST
-			case OpenstackResourceTypeHost:
-				logrus.Debugf("Resource is type host")
-
-				// Add all networks host is on as dependencies
-				for nk := range object.Host.Networks {
-					// Check the network exists in resources
-					if _, ok := resourceMap[nk]; !ok {
-						return extractResourceMetadataErrorReply("host %s depends on network %s which isn't defined", resource.Key, nk), nil
-					}
-					logrus.Debugf("\tAdding host dependency on network %s", nk)
-					reply.Metadata[resource.Key].DependsOnKeys = append(reply.Metadata[resource.Key].DependsOnKeys, nk)
-				}
-
-				// Set host features
-				reply.Metadata[resource.Key].Features = &pgrpc.Features{
-					Power:   true,
-					Console: true,
-				}
-
-				// Get the server flavor to determine quota requirements
-				var hostFlavor *flavors.Flavor = nil
-				allFlavorPages, err := flavors.ListDetail(computeClient, nil).AllPages()
-				if err != nil {
-					return extractResourceMetadataErrorReply("failed to get host %s flavor \"%s\": %v", resource.Key, object.Host.Flavor, err), nil
-				}
-				allFlavors, err := flavors.ExtractFlavors(allFlavorPages)
-				if err != nil {
-					return extractResourceMetadataErrorReply("failed to get host %s flavor \"%s\": %v", resource.Key, object.Host.Flavor, err), nil
-				}
-				for _, fl := range allFlavors {
-					if fl.Name == object.Host.Flavor || fl.ID == object.Host.Flavor {
-						hostFlavor = &fl
-						break
-					}
-				}
-				if hostFlavor == nil {
-					return extractResourceMetadataErrorReply("failed to get host %s flavor \"%s\": flavor not found", resource.Key, object.Host.Flavor), nil
-				}
-
-				// Set the quota requirements
-				reply.Metadata[resource.Key].QuotaRequirements = &pgrpc.QuotaRequirements{
-					Cpu:  uint64(hostFlavor.VCPUs),
-					Ram:  uint64(hostFlavor.RAM),         // Already in MiB
-					Disk: uint64(hostFlavor.Disk) * 1024, // Convert GiB to MiB
-				}
-			// NETWORK
-			case OpenstackResourceTypeNetwork:
-				logrus.Debugf("Resource is type network")
-
-				// Set network features
-				reply.Metadata[resource.Key].Features = &pgrpc.Features{
-					Power:   false,
-					Console: false,
-				}
-
-				// Set the quota requirements
-				reply.Metadata[resource.Key].QuotaRequirements = &pgrpc.QuotaRequirements{
-					Network: 1,
-				}
-			// ROUTER
-			case OpenstackResourceTypeRouter:
-				logrus.Debugf("Resource is type router")
-
-				// Add external network as dependency
-				if _, ok := resourceMap[object.Router.ExternalNetwork]; !ok {
-					return extractResourceMetadataErrorReply("router %s depends on external network %s which isn't defined", resource.Key, object.Router.ExternalNetwork), nil
-				}
-				logrus.Debugf("\tAdding router dependency on network %s", object.Router.ExternalNetwork)
-				reply.Metadata[resource.Key].DependsOnKeys = append(reply.Metadata[resource.Key].DependsOnKeys, object.Router.ExternalNetwork)
-
-				// Add all networks router is connected to as dependencies
-				for nk := range object.Router.Networks {
-					// Check the network exists in resources
-					if _, ok := resourceMap[nk]; !ok {
-						return extractResourceMetadataErrorReply("router %s depends on network %s which isn't defined", resource.Key, nk), nil
-					}
-					logrus.Debugf("\tAdding router dependency on network %s", nk)
-					reply.Metadata[resource.Key].DependsOnKeys = append(reply.Metadata[resource.Key].DependsOnKeys, nk)
-				}
-
-				// Set router features
-				reply.Metadata[resource.Key].Features = &pgrpc.Features{
-					Power:   false,
-					Console: false,
-				}
+			// Generate metadata with the handler of the type
+			handler, err := getResourceHandler(*object.Resource)
+			if err != nil {
+				return extractResourceMetadataErrorReply("%v", err), nil
+			}
+			logrus.Debugf("Resource is type %s", object.Resource.ShortName())
+			if err := handler.Metadata(resourceMap, resource.Key, object, reply.Metadata[resource.Key]); err != nil {
+				return extractResourceMetadataErrorReply("%v", err), nil
+			}
 
-				// Set the quota requirements
-				reply.Metadata[resource.Key].QuotaRequirements = &pgrpc.QuotaRequirements{
-					Router: 1,
-				}
+			// Set the quota requirements
+			quota, err := handler.Quota(computeClient, resourceMap, object)
+			if err != nil {
+				return extractResourceMetadataErrorReply("failed to get quota requirements for resource %s: %v", resource.Key, err), nil
 			}
+			logrus.Debugf("\tQuota requirements: %+v", *quota)
+			reply.Metadata[resource.Key].QuotaRequirements = quota.toGRPC()
+			totalQuota.Add(quota)
 
 			// Add dependencies based on depends_on
 			logrus.Debugf("Adding resource depends_on dependencies")
@@ -179,5 +113,72 @@ func (provider ProviderOpenstack) ExtractResourceMetadata(ctx context.Context, r
 		}
 	}
 
+	// Check the deployment fits in the project before anything is created
+	if CONFIG.QuotaPreflight {
+		logrus.Debugf("Checking quota requirements %+v against project limits", *totalQuota)
+		report, err := checkQuota(authClient, computeClient, totalQuota)
+		if err != nil {
+			return extractResourceMetadataErrorReply("failed to check quota: %v", err), nil
+		}
+		if err := report.Err(); err != nil {
+			return extractResourceMetadataErrorReply("%v", err), nil
+		}
+	}
+
 	return reply, nil
 }
+
+// hostMetadata adds the networks and data objects a host uses as dependencies
+func hostMetadata(resourceMap map[string]*pgrpc.Resource, key string, object *OpenstackObject, metadata *pgrpc.Metadata) error {
+	// Add all networks host is on as dependencies
+	for nk := range object.Host.Networks {
+		// Check the network exists in resources
+		if _, ok := resourceMap[nk]; !ok {
+			return fmt.Errorf("host %s depends on network %s which isn't defined", key, nk)
+		}
+		logrus.Debugf("\tAdding host dependency on network %s", nk)
+		metadata.DependsOnKeys = append(metadata.DependsOnKeys, nk)
+	}
+
+	// Add image, flavor and availability zone data objects as dependencies (if referenced by key)
+	for _, dataKey := range []string{object.Host.Image, object.Host.Flavor, object.Host.AvailabilityZone} {
+		if _, ok := resourceMap[dataKey]; ok && dataKey != "" {
+			logrus.Debugf("\tAdding host dependency on data %s", dataKey)
+			metadata.DependsOnKeys = append(metadata.DependsOnKeys, dataKey)
+		}
+	}
+
+	// Set host features
+	metadata.Features = &pgrpc.Features{
+		Power:   true,
+		Console: true,
+	}
+	return nil
+}
+
+// routerMetadata adds the networks a router is attached to as dependencies
+func routerMetadata(resourceMap map[string]*pgrpc.Resource, key string, object *OpenstackObject, metadata *pgrpc.Metadata) error {
+	// Add external network as dependency (if router has a gateway to a network in the blueprint,
+	// otherwise it's an existing Openstack network which is checked by the catalog validation)
+	if _, ok := resourceMap[object.Router.ExternalNetwork]; ok && object.Router.ExternalNetwork != "" {
+		logrus.Debugf("\tAdding router dependency on network %s", object.Router.ExternalNetwork)
+		metadata.DependsOnKeys = append(metadata.DependsOnKeys, object.Router.ExternalNetwork)
+	}
+
+	// Add all networks router is connected to as dependencies
+	for nk := range object.Router.Networks {
+		// Check the network exists in resources
+		if _, ok := resourceMap[nk]; !ok {
+			return fmt.Errorf("router %s depends on network %s which isn't defined", key, nk)
+		}
+		logrus.Debugf("\tAdding router dependency on network %s", nk)
+		metadata.DependsOnKeys = append(metadata.DependsOnKeys, nk)
+	}
+
+	// Set router features
+	metadata.Features = &pgrpc.Features{
+		Power:   false,
+		Console: false,
+	}
+	return nil
+}
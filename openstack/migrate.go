@@ -0,0 +1,140 @@
+package openstack
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ResourceMigration converts objects of an older version of a type (and the vars stored for them) to
+// a newer version, so blueprints and deployments written against the old version keep working
+type ResourceMigration struct {
+	// The type objects are converted to (e.g. openstack.v2.host)
+	To OpenstackResourceType
+	// Convert the decoded object to the new version (setting the typed config of the new version)
+	Object func(object *OpenstackObject) error
+	// Convert the vars stored for a resource of the old version (omit if the vars are unchanged)
+	Vars func(vars map[string]string) (map[string]string, error)
+}
+
+// The migration of every superseded type version
+var resourceMigrations = map[OpenstackResourceType]ResourceMigration{}
+
+// RegisterResourceMigration registers the conversion of a superseded type version to a newer one. The
+// handler of the old version only needs to decode its config (embedding baseResourceHandler), as
+// every other operation is run by the handler of the newest version
+func RegisterResourceMigration(from OpenstackResourceType, migration ResourceMigration) {
+	resourceMigrations[from] = migration
+}
+
+// Version is the version of the type (e.g. "v1")
+func (t OpenstackResourceType) Version() string {
+	parts := strings.SplitN(string(t), ".", 3)
+	if len(parts) != 3 {
+		return ""
+	}
+	return parts[1]
+}
+
+// ShortName is the type without the provider and version prefix (e.g. "host")
+func (t OpenstackResourceType) ShortName() string {
+	parts := strings.SplitN(string(t), ".", 3)
+	return parts[len(parts)-1]
+}
+
+// objectType returns the type of an object (as a resource or data)
+func (o *OpenstackObject) objectType() *OpenstackResourceType {
+	if o.Resource != nil {
+		return o.Resource
+	}
+	return o.Data
+}
+
+// migrateObject converts an object to the newest version of its type, recording the versions it
+// was converted from
+func migrateObject(object *OpenstackObject) error {
+	t := object.objectType()
+	if t == nil {
+		return nil
+	}
+	for {
+		migration, ok := resourceMigrations[*t]
+		if !ok {
+			return nil
+		}
+		// Guard against migrations which loop back to a previous version
+		for _, from := range object.MigratedFrom {
+			if from == *t {
+				return fmt.Errorf("migration of \"%s\" loops back to itself", from)
+			}
+		}
+		if _, err := getResourceHandler(migration.To); err != nil {
+			return fmt.Errorf("failed to migrate \"%s\": %v", *t, err)
+		}
+		if err := migration.Object(object); err != nil {
+			return fmt.Errorf("failed to migrate \"%s\" to \"%s\": %v", *t, migration.To, err)
+		}
+		object.MigratedFrom = append(object.MigratedFrom, *t)
+		to := migration.To
+		if object.Resource != nil {
+			object.Resource = &to
+		} else {
+			object.Data = &to
+		}
+		t = &to
+	}
+}
+
+// The var the type of a resource (or data) is saved in, so vars are only converted from the version
+// which saved them
+const typeVar = "_type"
+
+// migrateVars converts the vars stored for an object from the version which saved them to the newest
+// version (in the same order the object was migrated). Vars saved before the type was recorded are
+// assumed to be from the version the object was written as
+func migrateVars(object *OpenstackObject, vars map[string]string) (map[string]string, error) {
+	t := object.objectType()
+	if t == nil || len(vars) == 0 {
+		return vars, nil
+	}
+	savedType := *t
+	if len(object.MigratedFrom) > 0 {
+		savedType = object.MigratedFrom[0]
+	}
+	if recordedType, ok := vars[typeVar]; ok && recordedType != "" {
+		savedType = OpenstackResourceType(recordedType)
+	}
+	if savedType == *t {
+		return vars, nil
+	}
+
+	// Run the migrations from the version which saved the vars
+	start := -1
+	for i, from := range object.MigratedFrom {
+		if from == savedType {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil, fmt.Errorf("vars were saved by \"%s\", which doesn't migrate to \"%s\"", savedType, *t)
+	}
+	for _, from := range object.MigratedFrom[start:] {
+		migration := resourceMigrations[from]
+		if migration.Vars == nil {
+			continue
+		}
+		migratedVars, err := migration.Vars(vars)
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate vars of \"%s\" to \"%s\": %v", from, migration.To, err)
+		}
+		vars = migratedVars
+	}
+
+	// Record the version the vars are now in
+	migratedVars := make(map[string]string, len(vars)+1)
+	for k, v := range vars {
+		migratedVars[k] = v
+	}
+	migratedVars[typeVar] = string(*t)
+	return migratedVars, nil
+}
@@ -0,0 +1,135 @@
+package openstack
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+
+	pgrpc "github.com/cble-platform/cble-provider-grpc/pkg/provider"
+	"github.com/gophercloud/gophercloud"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	testTypeV1 OpenstackResourceType = "openstack.v1.migration_test"
+	testTypeV2 OpenstackResourceType = "openstack.v2.migration_test"
+)
+
+// testV1Handler only decodes the config of the superseded version
+type testV1Handler struct {
+	baseResourceHandler
+}
+
+func (testV1Handler) ConfigType() reflect.Type {
+	return reflect.TypeOf(OpenstackHost{})
+}
+
+func (testV1Handler) Decode(config *yaml.Node, object *OpenstackObject) error {
+	object.Host = new(OpenstackHost)
+	return decodeNode(config, "config", object.Host)
+}
+
+// testV2Handler records the vars it is called with
+type testV2Handler struct {
+	testV1Handler
+	deployVars  *[]map[string]string
+	destroyVars *[]map[string]string
+}
+
+func (h testV2Handler) Deploy(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.DeployResourceRequest, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	*h.deployVars = append(*h.deployVars, vars)
+	updatedVars := map[string]string{"id": "server-id"}
+	for k, v := range vars {
+		updatedVars[k] = v
+	}
+	return updatedVars, nil
+}
+
+func (h testV2Handler) Destroy(ctx context.Context, provider *ProviderOpenstack, authClient *gophercloud.ProviderClient, request *pgrpc.DestroyResourceRequest, object *OpenstackObject, vars map[string]string) (map[string]string, error) {
+	*h.destroyVars = append(*h.destroyVars, vars)
+	return map[string]string{}, nil
+}
+
+func TestMigrateVarsOnlyOnce(t *testing.T) {
+	withFakeIdentity(t)
+
+	// Register a v2 of the test type which converts both the object and the vars of v1
+	deployVars := []map[string]string{}
+	destroyVars := []map[string]string{}
+	RegisterResourceHandler(testTypeV1, testV1Handler{})
+	RegisterResourceHandler(testTypeV2, testV2Handler{deployVars: &deployVars, destroyVars: &destroyVars})
+	RegisterResourceMigration(testTypeV1, ResourceMigration{
+		To: testTypeV2,
+		Object: func(object *OpenstackObject) error {
+			object.Host.Name = &object.Host.Hostname
+			return nil
+		},
+		Vars: func(vars map[string]string) (map[string]string, error) {
+			// Count the conversions (vars must only be converted once)
+			migrated := map[string]string{}
+			for k, v := range vars {
+				migrated[k] = v
+			}
+			generation, _ := strconv.Atoi(vars["generation"])
+			migrated["generation"] = strconv.Itoa(generation + 1)
+			return migrated, nil
+		},
+	})
+	t.Cleanup(func() {
+		delete(resourceHandlers, testTypeV1)
+		delete(resourceHandlers, testTypeV2)
+		delete(resourceMigrations, testTypeV1)
+	})
+
+	// The blueprint keeps declaring v1
+	provider := ProviderOpenstack{}
+	deployment := &pgrpc.Deployment{Id: "00000000-0000-0000-0000-000000000000"}
+	resource := &pgrpc.Resource{
+		Id:     "host1",
+		Key:    "host1",
+		Object: []byte("resource: openstack.v1.migration_test\nconfig:\n  hostname: host1\n"),
+	}
+
+	// A fresh deploy has no vars to convert and records that its vars are v2
+	deployReply, err := provider.DeployResource(context.Background(), &pgrpc.DeployResourceRequest{
+		Deployment: deployment,
+		Resource:   resource,
+		Vars:       map[string]string{},
+	})
+	if err != nil || !deployReply.Success {
+		t.Fatalf("deploy failed: %v %v", err, deployReply.GetError())
+	}
+	if deployVars[0]["generation"] != "" {
+		t.Errorf("deploy converted empty vars: %v", deployVars[0])
+	}
+	if deployReply.UpdatedVars[typeVar] != string(testTypeV2) {
+		t.Errorf("deploy saved type %q, want %q", deployReply.UpdatedVars[typeVar], testTypeV2)
+	}
+
+	// Destroy gets the vars saved by the v2 deploy unchanged
+	destroyReply, err := provider.DestroyResource(context.Background(), &pgrpc.DestroyResourceRequest{
+		Deployment: deployment,
+		Resource:   resource,
+		Vars:       deployReply.UpdatedVars,
+	})
+	if err != nil || !destroyReply.Success {
+		t.Fatalf("destroy failed: %v %v", err, destroyReply.GetError())
+	}
+	if destroyVars[0]["generation"] != "" {
+		t.Errorf("destroy converted vars saved by v2 again: %v", destroyVars[0])
+	}
+
+	// Vars saved by v1 (without a type) are converted exactly once
+	destroyReply, err = provider.DestroyResource(context.Background(), &pgrpc.DestroyResourceRequest{
+		Deployment: deployment,
+		Resource:   resource,
+		Vars:       map[string]string{"id": "server-id"},
+	})
+	if err != nil || !destroyReply.Success {
+		t.Fatalf("destroy failed: %v %v", err, destroyReply.GetError())
+	}
+	if destroyVars[1]["generation"] != "1" || destroyVars[1][typeVar] != string(testTypeV2) {
+		t.Errorf("destroy didn't convert v1 vars once: %v", destroyVars[1])
+	}
+}
@@ -0,0 +1,140 @@
+package openstack
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	pgrpc "github.com/cble-platform/cble-provider-grpc/pkg/provider"
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/blockstorage/v3/volumes"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/volumeattach"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/attributestags"
+	"github.com/sirupsen/logrus"
+)
+
+// The default name template (first 8 bytes of deployment ID and the object name)
+const defaultNameTemplate = "{{ .Deployment.ShortID }}-{{ .Name }}"
+
+// Prefixes of the tags/metadata added to every created object
+const (
+	tagDeploymentPrefix = "cble-deployment="
+	tagResourcePrefix   = "cble-resource="
+	tagProvider         = "cble-provider=" + name
+
+	metadataDeploymentKey  = "cble_deployment_id"
+	metadataResourceKey    = "cble_resource_id"
+	metadataResourceKeyKey = "cble_resource_key"
+)
+
+type nameTemplateDeployment struct {
+	// The full deployment ID
+	ID string
+	// The first 8 bytes of the deployment ID
+	ShortID string
+	// The deployment template vars (e.g. deployment name or user, if provided by CBLE)
+	Vars map[string]string
+}
+
+type nameTemplateData struct {
+	// The deployment the object belongs to
+	Deployment nameTemplateDeployment
+	// The CBLE resource ID
+	ResourceID string
+	// The blueprint key of the resource
+	Key string
+	// The name of the object (name, hostname or key)
+	Name string
+}
+
+// parseNameTemplate parses the configured name template (or the default if not set)
+func parseNameTemplate(nameTemplate string) (*template.Template, error) {
+	if nameTemplate == "" {
+		nameTemplate = defaultNameTemplate
+	}
+	return template.New("name").Option("missingkey=error").Parse(nameTemplate)
+}
+
+// objectName generates the name of an Openstack object using the configured name template
+func objectName(deployment *pgrpc.Deployment, resource *pgrpc.Resource, name string) (string, error) {
+	nameTemplate := CONFIG.nameTemplate
+	if nameTemplate == nil {
+		var err error
+		if nameTemplate, err = parseNameTemplate(CONFIG.NameTemplate); err != nil {
+			return "", fmt.Errorf("failed to parse name template: %v", err)
+		}
+	}
+
+	shortId := deployment.Id
+	if len(shortId) > 8 {
+		shortId = shortId[:8]
+	}
+
+	var buf bytes.Buffer
+	err := nameTemplate.Execute(&buf, nameTemplateData{
+		Deployment: nameTemplateDeployment{
+			ID:      deployment.Id,
+			ShortID: shortId,
+			Vars:    deployment.TemplateVars,
+		},
+		ResourceID: resource.Id,
+		Key:        resource.Key,
+		Name:       name,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate name: %v", err)
+	}
+	return buf.String(), nil
+}
+
+// objectTags generates the Neutron tags which record the CBLE deployment and resource of an object
+func objectTags(deployment *pgrpc.Deployment, resource *pgrpc.Resource) []string {
+	return []string{
+		tagProvider,
+		tagDeploymentPrefix + deployment.Id,
+		tagResourcePrefix + resource.Id,
+	}
+}
+
+// objectMetadata generates the Nova metadata which records the CBLE deployment and resource of a server
+func objectMetadata(deployment *pgrpc.Deployment, resource *pgrpc.Resource) map[string]string {
+	return map[string]string{
+		metadataDeploymentKey:  deployment.Id,
+		metadataResourceKey:    resource.Id,
+		metadataResourceKeyKey: resource.Key,
+	}
+}
+
+// tagNetworkObject sets the CBLE tags on a Neutron object (e.g. "networks", "subnets", "routers", "ports").
+// Failures are only logged as not every cloud has the tagging extension enabled
+func tagNetworkObject(networkClient *gophercloud.ServiceClient, objectType string, objectId string, tags []string) {
+	_, err := attributestags.ReplaceAll(networkClient, objectType, objectId, attributestags.ReplaceAllOpts{
+		Tags: tags,
+	}).Extract()
+	if err != nil {
+		logrus.Warnf("failed to tag %s %s: %v", objectType, objectId, err)
+	}
+}
+
+// tagServerVolumes sets the CBLE metadata on every volume attached to a server.
+// Failures are only logged as the volumes are deleted along with the server
+func tagServerVolumes(computeClient *gophercloud.ServiceClient, blockStorageClient *gophercloud.ServiceClient, serverId string, metadata map[string]string) {
+	allAttachmentPages, err := volumeattach.List(computeClient, serverId).AllPages()
+	if err != nil {
+		logrus.Warnf("failed to list volumes of server %s: %v", serverId, err)
+		return
+	}
+	allAttachments, err := volumeattach.ExtractVolumeAttachments(allAttachmentPages)
+	if err != nil {
+		logrus.Warnf("failed to list volumes of server %s: %v", serverId, err)
+		return
+	}
+	for _, attachment := range allAttachments {
+		_, err = volumes.Update(blockStorageClient, attachment.VolumeID, volumes.UpdateOpts{
+			Metadata: metadata,
+		}).Extract()
+		if err != nil {
+			logrus.Warnf("failed to tag volume %s: %v", attachment.VolumeID, err)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package openstack
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParseError is a problem decoding a blueprint object, located in the YAML it came from
+type ParseError struct {
+	// The key of the object with the problem (if known)
+	Key string
+	// The path of the field with the problem within the object (if known)
+	Field string
+	// The line of the problem in the YAML
+	Line int
+	// The column of the problem in the YAML
+	Column int
+	// The problem
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	location := fmt.Sprintf("line %d, column %d", e.Line, e.Column)
+	if e.Field != "" {
+		location = fmt.Sprintf("%s (%s)", e.Field, location)
+	}
+	if e.Key != "" {
+		return fmt.Sprintf("%s: %s: %v", e.Key, location, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", location, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// newParseError locates a problem at a node (keeping the location of errors which are already located)
+func newParseError(n *yaml.Node, field string, err error) error {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		return err
+	}
+	return &ParseError{
+		Field:  field,
+		Line:   n.Line,
+		Column: n.Column,
+		Err:    err,
+	}
+}
+
+// withParseKey tags a parse error with the key of the object it came from
+func withParseKey(key string, n *yaml.Node, err error) error {
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		parseErr = newParseError(n, "", err).(*ParseError)
+	}
+	if parseErr.Key == "" {
+		parseErr.Key = key
+	}
+	return parseErr
+}
+
+// parseObject unmarshals the object of a resource, tagging any parse error with the resource key
+func parseObject(key string, in []byte) (*OpenstackObject, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(in, &root); err != nil {
+		return nil, fmt.Errorf("%s: %v", key, err)
+	}
+	object := &OpenstackObject{}
+	if len(root.Content) == 0 {
+		return nil, withParseKey(key, &root, fmt.Errorf("object is empty"))
+	}
+	if err := root.Content[0].Decode(object); err != nil {
+		return nil, withParseKey(key, root.Content[0], err)
+	}
+	return object, nil
+}
+
+// decodeNode decodes a node into out, locating any problem at the exact node (and field) it came from.
+// The YAML decoder doesn't report the location of errors from text unmarshalers (e.g. netip parsing)
+// and only reports the line of type errors, so failed decodes are retried one value at a time
+func decodeNode(n *yaml.Node, field string, out any) error {
+	err := n.Decode(out)
+	if err == nil {
+		return nil
+	}
+	if badNode, badField, badErr := locateDecodeError(n, reflect.TypeOf(out), field); badNode != nil {
+		return newParseError(badNode, badField, badErr)
+	}
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		parseErr.Field = joinField(field, parseErr.Field)
+		return parseErr
+	}
+	return newParseError(n, field, err)
+}
+
+var (
+	nodeType            = reflect.TypeOf(yaml.Node{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	yamlUnmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+)
+
+// locateDecodeError walks a node alongside the type it decodes into and returns the first value which
+// fails to decode (or a nil node if every value decodes on its own)
+func locateDecodeError(n *yaml.Node, t reflect.Type, field string) (*yaml.Node, string, error) {
+	if n.Kind == yaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	// Types with their own decoding are checked as a whole
+	custom := reflect.PointerTo(t).Implements(textUnmarshalerType) || reflect.PointerTo(t).Implements(yamlUnmarshalerType) || t == nodeType
+
+	switch {
+	case !custom && t.Kind() == reflect.Struct && n.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			name := n.Content[i].Value
+			fieldType, ok := yamlFieldType(t, name)
+			if !ok {
+				continue
+			}
+			if badNode, badField, err := locateDecodeError(n.Content[i+1], fieldType, joinField(field, name)); badNode != nil {
+				return badNode, badField, err
+			}
+		}
+		return nil, "", nil
+	case !custom && t.Kind() == reflect.Map && n.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if badNode, badField, err := locateDecodeError(n.Content[i], t.Key(), field); badNode != nil {
+				return badNode, badField, err
+			}
+			if badNode, badField, err := locateDecodeError(n.Content[i+1], t.Elem(), joinField(field, n.Content[i].Value)); badNode != nil {
+				return badNode, badField, err
+			}
+		}
+		return nil, "", nil
+	case !custom && t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 && n.Kind == yaml.SequenceNode:
+		for i, item := range n.Content {
+			if badNode, badField, err := locateDecodeError(item, t.Elem(), fmt.Sprintf("%s[%d]", field, i)); badNode != nil {
+				return badNode, badField, err
+			}
+		}
+		return nil, "", nil
+	}
+
+	// Decode the value on its own (problems located by custom decoding are relative to the value)
+	if err := n.Decode(reflect.New(t).Interface()); err != nil {
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			parseErr.Field = joinField(field, parseErr.Field)
+			return n, parseErr.Field, parseErr
+		}
+		return n, field, stripLine(err)
+	}
+	return nil, "", nil
+}
+
+// yamlFieldType finds the type of the struct field decoded from a YAML key (including inline structs)
+func yamlFieldType(t reflect.Type, name string) (reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("yaml"), ",")
+		for _, option := range tag[1:] {
+			if option == "inline" && field.Type.Kind() == reflect.Struct {
+				if fieldType, ok := yamlFieldType(field.Type, name); ok {
+					return fieldType, true
+				}
+			}
+		}
+		fieldName := tag[0]
+		if fieldName == "" {
+			fieldName = strings.ToLower(field.Name)
+		}
+		if fieldName == name {
+			return field.Type, true
+		}
+	}
+	return nil, false
+}
+
+// joinField appends a key to a field path
+func joinField(field string, name string) string {
+	switch {
+	case field == "":
+		return name
+	case name == "":
+		return field
+	case strings.HasPrefix(name, "["):
+		return field + name
+	default:
+		return field + "." + name
+	}
+}
+
+// stripLine removes the line prefix from YAML type errors (as the location is reported separately)
+func stripLine(err error) error {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return err
+	}
+	problems := make([]string, len(typeErr.Errors))
+	for i, problem := range typeErr.Errors {
+		if strings.HasPrefix(problem, "line ") {
+			if _, rest, ok := strings.Cut(problem, ": "); ok {
+				problem = rest
+			}
+		}
+		problems[i] = problem
+	}
+	return errors.New(strings.Join(problems, "; "))
+}
+
+// mappingValue returns the value of a key in a mapping node (or nil if it isn't set)
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package openstack
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseErrorError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *ParseError
+		want string
+	}{
+		{
+			name: "location only",
+			err:  &ParseError{Line: 2, Column: 3, Err: fmt.Errorf("bad")},
+			want: "line 2, column 3: bad",
+		},
+		{
+			name: "field",
+			err:  &ParseError{Field: "subnet", Line: 2, Column: 3, Err: fmt.Errorf("bad")},
+			want: "subnet (line 2, column 3): bad",
+		},
+		{
+			name: "key and field",
+			err:  &ParseError{Key: "network1", Field: "subnet", Line: 2, Column: 3, Err: fmt.Errorf("bad")},
+			want: "network1: subnet (line 2, column 3): bad",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	inner := fmt.Errorf("bad")
+	if err := error(&ParseError{Err: inner}); !errors.Is(err, inner) {
+		t.Errorf("parse error doesn't unwrap to %v", inner)
+	}
+}
+
+func TestDecodeNode(t *testing.T) {
+	type nested struct {
+		Attachments map[string]OpenstackNetworkAttachment `yaml:"attachments"`
+		Prefixes    []netip.Prefix                        `yaml:"prefixes"`
+		Count       int                                   `yaml:"count"`
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		// Expected location of the problem (zero if the value must decode)
+		wantField  string
+		wantLine   int
+		wantColumn int
+		wantErr    string
+	}{
+		{
+			name: "valid",
+			in:   "attachments: {network1: {ip: 10.0.0.10}}\nprefixes: [10.0.0.0/24]\ncount: 1",
+		},
+		{
+			name:       "bad ip in a nested map",
+			in:         "count: 1\nattachments:\n  network1:\n    ip: 10.0.0.10\n  network2:\n    ip: 10.0.0.300",
+			wantField:  "attachments.network2.ip",
+			wantLine:   6,
+			wantColumn: 9,
+			wantErr:    "10.0.0.300",
+		},
+		{
+			name:       "bad prefix in a list",
+			in:         "prefixes:\n  - 10.0.0.0/24\n  - 10.0.1.0/33",
+			wantField:  "prefixes[1]",
+			wantLine:   3,
+			wantColumn: 5,
+			wantErr:    "10.0.1.0/33",
+		},
+		{
+			name:       "type error",
+			in:         "count: many",
+			wantField:  "count",
+			wantLine:   1,
+			wantColumn: 8,
+			wantErr:    "cannot unmarshal !!str `many` into int",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var root yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.in), &root); err != nil {
+				t.Fatalf("invalid test yaml: %v", err)
+			}
+			var out nested
+			err := decodeNode(root.Content[0], "", &out)
+			if tt.wantLine == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("expected a parse error, got %v", err)
+			}
+			if parseErr.Field != tt.wantField || parseErr.Line != tt.wantLine || parseErr.Column != tt.wantColumn {
+				t.Errorf("got problem at %s (line %d, column %d), want %s (line %d, column %d)", parseErr.Field, parseErr.Line, parseErr.Column, tt.wantField, tt.wantLine, tt.wantColumn)
+			}
+			if !strings.Contains(parseErr.Err.Error(), tt.wantErr) {
+				t.Errorf("got error %q, want it to contain %q", parseErr.Err, tt.wantErr)
+			}
+			if strings.Contains(parseErr.Err.Error(), "line ") {
+				t.Errorf("error %q repeats the line", parseErr.Err)
+			}
+		})
+	}
+}
+
+func TestLocateDecodeError(t *testing.T) {
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte("subnet: 10.0.0.0/24\ngateway: 10.0.0.1"), &root); err != nil {
+		t.Fatalf("invalid test yaml: %v", err)
+	}
+	if badNode, _, _ := locateDecodeError(root.Content[0], reflect.TypeOf(OpenstackNetwork{}), ""); badNode != nil {
+		t.Errorf("valid network located a problem at line %d", badNode.Line)
+	}
+
+	if err := yaml.Unmarshal([]byte("subnet: 10.0.0.0/24\ngateway: 10.0.0.1/24"), &root); err != nil {
+		t.Fatalf("invalid test yaml: %v", err)
+	}
+	badNode, badField, err := locateDecodeError(root.Content[0], reflect.TypeOf(OpenstackNetwork{}), "config")
+	if badNode == nil {
+		t.Fatalf("expected a problem to be located")
+	}
+	if badField != "config.gateway" || badNode.Line != 2 || badNode.Column != 10 || err == nil {
+		t.Errorf("got problem %v at %s (line %d, column %d), want config.gateway (line 2, column 10)", err, badField, badNode.Line, badNode.Column)
+	}
+}
+
+func TestParseObject(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		// Expected location of the problem (zero if the object must parse)
+		wantField  string
+		wantLine   int
+		wantColumn int
+		wantErr    string
+	}{
+		{
+			name: "valid network",
+			in:   "resource: openstack.v1.network\nconfig:\n  subnet: 10.0.0.0/24",
+		},
+		{
+			name:       "bad subnet prefix",
+			in:         "resource: openstack.v1.network\nconfig:\n  subnet: 10.0.0.0/40",
+			wantField:  "config.subnet",
+			wantLine:   3,
+			wantColumn: 11,
+			wantErr:    "10.0.0.0/40",
+		},
+		{
+			name:       "bad ip in a nested map",
+			in:         "resource: openstack.v1.host\nconfig:\n  networks:\n    network1:\n      ip: 10.0.0.x",
+			wantField:  "config.networks.network1.ip",
+			wantLine:   5,
+			wantColumn: 11,
+			wantErr:    "10.0.0.x",
+		},
+		{
+			name:       "unknown type",
+			in:         "resource: openstack.v1.nothing",
+			wantField:  "resource",
+			wantLine:   1,
+			wantColumn: 11,
+			wantErr:    "openstack.v1.nothing",
+		},
+		{
+			name:       "empty object",
+			in:         "",
+			wantLine:   0,
+			wantColumn: 0,
+			wantErr:    "object is empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			object, err := parseObject("object1", []byte(tt.in))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if object.Network == nil {
+					t.Errorf("network config was not decoded: %+v", object)
+				}
+				return
+			}
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("expected a parse error, got %v", err)
+			}
+			if parseErr.Key != "object1" {
+				t.Errorf("got key %q, want %q", parseErr.Key, "object1")
+			}
+			if parseErr.Field != tt.wantField || parseErr.Line != tt.wantLine || parseErr.Column != tt.wantColumn {
+				t.Errorf("got problem at %s (line %d, column %d), want %s (line %d, column %d)", parseErr.Field, parseErr.Line, parseErr.Column, tt.wantField, tt.wantLine, tt.wantColumn)
+			}
+			if !strings.Contains(parseErr.Err.Error(), tt.wantErr) {
+				t.Errorf("got error %q, want it to contain %q", parseErr.Err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBlueprintParseErrorKey(t *testing.T) {
+	in := networkYAML + `
+network2:
+  resource: openstack.v1.network
+  config:
+    subnet: 10.0.1.0/24
+    dhcp:
+      - start: 10.0.1.100
+        end: 10.0.1.x
+`
+	var blueprint OpenstackBlueprint
+	err := yaml.Unmarshal([]byte(in), &blueprint)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected a parse error, got %v", err)
+	}
+	if parseErr.Key != "network2" || parseErr.Field != "config.dhcp[0].end" || parseErr.Line != 16 || parseErr.Column != 14 {
+		t.Errorf("got problem at %s: %s (line %d, column %d), want network2: config.dhcp[0].end (line 16, column 14)", parseErr.Key, parseErr.Field, parseErr.Line, parseErr.Column)
+	}
+}
@@ -10,7 +10,6 @@ import (
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/startstop"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
 	"github.com/sirupsen/logrus"
-	"gopkg.in/yaml.v3"
 )
 
 func (provider ProviderOpenstack) ResourcePower(ctx context.Context, request *pgrpc.ResourcePowerRequest) (*pgrpc.ResourcePowerReply, error) {
@@ -35,7 +34,7 @@ func (provider ProviderOpenstack) ResourcePower(ctx context.Context, request *pg
 
 	// Unmarshal the object YAML as struct
 	var object *OpenstackObject
-	err = yaml.Unmarshal(request.Resource.Object, &object)
+	object, err = parseObject(request.Resource.Key, request.Resource.Object)
 	if err != nil {
 		return &pgrpc.ResourcePowerReply{
 			Success: false,
@@ -51,28 +50,26 @@ func (provider ProviderOpenstack) ResourcePower(ctx context.Context, request *pg
 		}, nil
 	}
 
-	// Check the resource type (only allow power modifications to servers)
-	if *object.Resource != OpenstackResourceTypeHost {
+	// Convert vars stored for a superseded version of the type
+	request.Vars, err = migrateVars(object, request.Vars)
+	if err != nil {
 		return &pgrpc.ResourcePowerReply{
 			Success: false,
-			Error:   Errorf("cannot modify power state for this resource"),
+			Error:   Errorf("%v", err),
 		}, nil
 	}
 
-	switch request.State {
-	case pgrpc.PowerState_ON:
-		err = provider.powerOnResource(ctx, authClient, request, object)
-	case pgrpc.PowerState_OFF:
-		err = provider.powerOffResource(ctx, authClient, request, object)
-	case pgrpc.PowerState_RESET:
-		err = provider.resetResource(ctx, authClient, request, object)
-	default:
+	// Get the handler of the resource type
+	handler, err := getResourceHandler(*object.Resource)
+	if err != nil {
 		return &pgrpc.ResourcePowerReply{
 			Success: false,
-			Error:   Errorf("power state \"%s\" is unknown", request.State),
+			Error:   Errorf("%v", err),
 		}, nil
 	}
 
+	// Change the power state (only supported by some types, e.g. hosts)
+	err = handler.Power(ctx, &provider, authClient, request, object)
 	if err != nil {
 		return &pgrpc.ResourcePowerReply{
 			Success: false,
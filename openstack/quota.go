@@ -0,0 +1,297 @@
+package openstack
+
+import (
+	"fmt"
+	"strings"
+
+	pgrpc "github.com/cble-platform/cble-provider-grpc/pkg/provider"
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	"github.com/gophercloud/gophercloud/openstack/blockstorage/extensions/quotasets"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/limits"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/quotas"
+)
+
+// OpenstackQuotaRequirements are the Nova, Cinder and Neutron quotas used by a resource.
+// CBLE only tracks a subset of these (see toGRPC)
+type OpenstackQuotaRequirements struct {
+	// Nova instances
+	Instances int
+	// Nova cores
+	Cores int
+	// Nova RAM (in MiB)
+	RAM int
+	// Cinder volumes
+	Volumes int
+	// Cinder volume storage (in GiB)
+	VolumeGigabytes int
+	// Neutron networks
+	Networks int
+	// Neutron subnets
+	Subnets int
+	// Neutron routers
+	Routers int
+	// Neutron ports
+	Ports int
+}
+
+// Add adds the requirements of another resource to the requirements
+func (q *OpenstackQuotaRequirements) Add(other *OpenstackQuotaRequirements) {
+	q.Instances += other.Instances
+	q.Cores += other.Cores
+	q.RAM += other.RAM
+	q.Volumes += other.Volumes
+	q.VolumeGigabytes += other.VolumeGigabytes
+	q.Networks += other.Networks
+	q.Subnets += other.Subnets
+	q.Routers += other.Routers
+	q.Ports += other.Ports
+}
+
+// toGRPC converts the requirements into the quota requirements tracked by CBLE
+func (q *OpenstackQuotaRequirements) toGRPC() *pgrpc.QuotaRequirements {
+	return &pgrpc.QuotaRequirements{
+		Cpu:     uint64(q.Cores),
+		Ram:     uint64(q.RAM),                    // Already in MiB
+		Disk:    uint64(q.VolumeGigabytes) * 1024, // Convert GiB to MiB
+		Router:  uint64(q.Routers),
+		Network: uint64(q.Networks),
+	}
+}
+
+// resolveHostFlavor gets the flavor of a host, selecting it the same way the flavor data object
+// will if the flavor references one by key
+func resolveHostFlavor(computeClient *gophercloud.ServiceClient, resourceMap map[string]*pgrpc.Resource, host *OpenstackHost) (*flavors.Flavor, error) {
+	flavorResource, ok := resourceMap[host.Flavor]
+	if !ok {
+		return CATALOG.Flavor(computeClient, host.Flavor)
+	}
+	flavorObject, err := parseObject(flavorResource.Key, flavorResource.Object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse object: %v", err)
+	}
+	if flavorObject.Flavor == nil {
+		return nil, fmt.Errorf("%s isn't a flavor data object", host.Flavor)
+	}
+	return selectFlavor(computeClient, flavorObject.Flavor)
+}
+
+// hostQuotaRequirements calculates the quota used by a host. Hosts always boot from a Cinder
+// volume of disk_size, so the flavor root/ephemeral disk isn't counted
+func hostQuotaRequirements(host *OpenstackHost, flavor *flavors.Flavor) *OpenstackQuotaRequirements {
+	return &OpenstackQuotaRequirements{
+		Instances:       1,
+		Cores:           flavor.VCPUs,
+		RAM:             flavor.RAM,
+		Volumes:         1,
+		VolumeGigabytes: host.DiskSize,
+		// One port per network attachment (the default security group is used, so none are created)
+		Ports: len(host.Networks),
+	}
+}
+
+// networkQuotaRequirements calculates the quota used by a network
+func networkQuotaRequirements(network *OpenstackNetwork) *OpenstackQuotaRequirements {
+	return &OpenstackQuotaRequirements{
+		Networks: 1,
+		Subnets:  1,
+		// DHCP is always enabled on the subnet, which creates a DHCP port in the project
+		Ports: 1,
+	}
+}
+
+// routerQuotaRequirements calculates the quota used by a router
+func routerQuotaRequirements(router *OpenstackRouter) *OpenstackQuotaRequirements {
+	return &OpenstackQuotaRequirements{
+		Routers: 1,
+		// One interface port per attached network (the gateway port isn't owned by the project)
+		Ports: len(router.Networks),
+	}
+}
+
+// objectQuotaRequirements calculates the quota used by a resource object (data objects use no quota)
+func objectQuotaRequirements(computeClient *gophercloud.ServiceClient, resourceMap map[string]*pgrpc.Resource, object *OpenstackObject) (*OpenstackQuotaRequirements, error) {
+	if object.Resource == nil {
+		return &OpenstackQuotaRequirements{}, nil
+	}
+	handler, err := getResourceHandler(*object.Resource)
+	if err != nil {
+		return nil, err
+	}
+	return handler.Quota(computeClient, resourceMap, object)
+}
+
+type QuotaCheckItem struct {
+	// The service the quota belongs to (compute, volume or network)
+	Service string
+	// The name of the quota (e.g. cores)
+	Quota string
+	// The project limit (-1 is unlimited)
+	Limit int
+	// The amount already used (or reserved) in the project
+	InUse int
+	// The amount required by the deployment
+	Required int
+}
+
+// Available returns the amount of quota left in the project (-1 is unlimited)
+func (i QuotaCheckItem) Available() int {
+	if i.Limit < 0 {
+		return -1
+	}
+	if i.InUse > i.Limit {
+		return 0
+	}
+	return i.Limit - i.InUse
+}
+
+// Shortfall returns how much more quota the deployment needs than is available
+func (i QuotaCheckItem) Shortfall() int {
+	available := i.Available()
+	if available < 0 || i.Required <= available {
+		return 0
+	}
+	return i.Required - available
+}
+
+type QuotaReport struct {
+	// The total quota requirements of the deployment
+	Required OpenstackQuotaRequirements
+	// The requirements checked against each project limit
+	Items []QuotaCheckItem
+}
+
+// Shortfalls returns the quotas which don't have enough room for the deployment
+func (r *QuotaReport) Shortfalls() []QuotaCheckItem {
+	shortfalls := []QuotaCheckItem{}
+	for _, item := range r.Items {
+		if item.Shortfall() > 0 {
+			shortfalls = append(shortfalls, item)
+		}
+	}
+	return shortfalls
+}
+
+// Err returns an error describing every shortfall (or nil if the deployment fits)
+func (r *QuotaReport) Err() error {
+	shortfalls := r.Shortfalls()
+	if len(shortfalls) == 0 {
+		return nil
+	}
+	descriptions := make([]string, len(shortfalls))
+	for i, item := range shortfalls {
+		descriptions[i] = fmt.Sprintf("%s %s (required %d, available %d, short by %d)", item.Service, item.Quota, item.Required, item.Available(), item.Shortfall())
+	}
+	return fmt.Errorf("insufficient quota: %s", strings.Join(descriptions, "; "))
+}
+
+// CheckQuota sums the quota requirements of every resource in a deployment and checks them against
+// the live Nova limits, Cinder quotas and Neutron quotas of the project
+func (provider ProviderOpenstack) CheckQuota(resources []*pgrpc.Resource) (*QuotaReport, error) {
+	// Check if the provider has been configured
+	if CONFIG == nil {
+		return nil, fmt.Errorf("cannot check quota with unconfigured provider, please call Configure()")
+	}
+
+	// Generate authenticated client session
+	authClient, err := provider.newAuthClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to authenticate: %v", err)
+	}
+
+	// Generate the Compute V2 client
+	computeClient, err := openstack.NewComputeV2(authClient, gophercloud.EndpointOpts{
+		Region: CONFIG.RegionName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compute client: %v", err)
+	}
+
+	// Convert the resource list into a key:resource map
+	resourceMap := make(map[string]*pgrpc.Resource)
+	for _, resource := range resources {
+		resourceMap[resource.Key] = resource
+	}
+
+	// Sum the requirements of every resource
+	required := &OpenstackQuotaRequirements{}
+	for _, resource := range resources {
+		object, err := parseObject(resource.Key, resource.Object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse object: %v", err)
+		}
+		quota, err := objectQuotaRequirements(computeClient, resourceMap, object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get quota requirements for resource %s: %v", resource.Key, err)
+		}
+		required.Add(quota)
+	}
+
+	return checkQuota(authClient, computeClient, required)
+}
+
+// checkQuota checks the requirements against the live project limits
+func checkQuota(authClient *gophercloud.ProviderClient, computeClient *gophercloud.ServiceClient, required *OpenstackQuotaRequirements) (*QuotaReport, error) {
+	report := &QuotaReport{
+		Required: *required,
+	}
+
+	// Get the Nova limits
+	computeLimits, err := limits.Get(computeClient, nil).Extract()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get compute limits: %v", err)
+	}
+	report.Items = append(report.Items,
+		QuotaCheckItem{"compute", "instances", computeLimits.Absolute.MaxTotalInstances, computeLimits.Absolute.TotalInstancesUsed, required.Instances},
+		QuotaCheckItem{"compute", "cores", computeLimits.Absolute.MaxTotalCores, computeLimits.Absolute.TotalCoresUsed, required.Cores},
+		QuotaCheckItem{"compute", "ram", computeLimits.Absolute.MaxTotalRAMSize, computeLimits.Absolute.TotalRAMUsed, required.RAM},
+	)
+
+	// The Cinder and Neutron quotas are looked up by project
+	osProjectId, err := projectID(authClient)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate the Block Storage V3 client
+	blockStorageClient, err := openstack.NewBlockStorageV3(authClient, gophercloud.EndpointOpts{
+		Region: CONFIG.RegionName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create block storage client: %v", err)
+	}
+
+	// Get the Cinder quota usage
+	volumeQuota, err := quotasets.GetUsage(blockStorageClient, osProjectId).Extract()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get volume quotas: %v", err)
+	}
+	report.Items = append(report.Items,
+		QuotaCheckItem{"volume", "volumes", volumeQuota.Volumes.Limit, volumeQuota.Volumes.InUse + volumeQuota.Volumes.Reserved, required.Volumes},
+		QuotaCheckItem{"volume", "gigabytes", volumeQuota.Gigabytes.Limit, volumeQuota.Gigabytes.InUse + volumeQuota.Gigabytes.Reserved, required.VolumeGigabytes},
+	)
+
+	// Generate the Network V2 client
+	networkClient, err := openstack.NewNetworkV2(authClient, gophercloud.EndpointOpts{
+		Name:   "neutron",
+		Region: CONFIG.RegionName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create openstack network client: %v", err)
+	}
+
+	// Get the Neutron quota details
+	networkQuota, err := quotas.GetDetail(networkClient, osProjectId).Extract()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get network quotas: %v", err)
+	}
+	report.Items = append(report.Items,
+		QuotaCheckItem{"network", "networks", networkQuota.Network.Limit, networkQuota.Network.Used + networkQuota.Network.Reserved, required.Networks},
+		QuotaCheckItem{"network", "subnets", networkQuota.Subnet.Limit, networkQuota.Subnet.Used + networkQuota.Subnet.Reserved, required.Subnets},
+		QuotaCheckItem{"network", "routers", networkQuota.Router.Limit, networkQuota.Router.Used + networkQuota.Router.Reserved, required.Routers},
+		QuotaCheckItem{"network", "ports", networkQuota.Port.Limit, networkQuota.Port.Used + networkQuota.Port.Reserved, required.Ports},
+	)
+
+	return report, nil
+}
@@ -0,0 +1,63 @@
+package openstack
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+)
+
+func TestCheckQuota(t *testing.T) {
+	withFakeCloud(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/compute/limits":
+			w.Write([]byte(`{"limits": {"absolute": {"maxTotalInstances": 10, "totalInstancesUsed": 8, "maxTotalCores": 20, "totalCoresUsed": 18, "maxTotalRAMSize": -1, "totalRAMUsed": 4096}}}`))
+		// The quotas are looked up for the project of the token (only the project name is configured)
+		case "/volume/os-quota-sets/test-project-id":
+			w.Write([]byte(`{"quota_set": {"id": "test-project-id", "volumes": {"limit": 10, "in_use": 2, "reserved": 1}, "gigabytes": {"limit": 100, "in_use": 90, "reserved": 0}}}`))
+		case "/network/v2.0/quotas/test-project-id/details.json":
+			w.Write([]byte(`{"quota": {"network": {"limit": 10, "used": 1, "reserved": 0}, "subnet": {"limit": 10, "used": 1, "reserved": 0}, "router": {"limit": 5, "used": 5, "reserved": 0}, "port": {"limit": 50, "used": 10, "reserved": 2}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	authClient, err := ProviderOpenstack{}.newAuthClient()
+	if err != nil {
+		t.Fatalf("failed to authenticate: %v", err)
+	}
+	computeClient, err := openstack.NewComputeV2(authClient, gophercloud.EndpointOpts{})
+	if err != nil {
+		t.Fatalf("failed to create compute client: %v", err)
+	}
+
+	report, err := checkQuota(authClient, computeClient, &OpenstackQuotaRequirements{
+		Instances:       2,
+		Cores:           4,
+		RAM:             8192,
+		Volumes:         2,
+		VolumeGigabytes: 20,
+		Networks:        1,
+		Subnets:         1,
+		Routers:         1,
+		Ports:           5,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []QuotaCheckItem{
+		{"compute", "cores", 20, 18, 4},
+		{"volume", "gigabytes", 100, 90, 20},
+		{"network", "routers", 5, 5, 1},
+	}
+	if got := report.Shortfalls(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got shortfalls %+v, want %+v", got, want)
+	}
+	if len(report.Items) != 9 {
+		t.Errorf("got %d checks, want 9: %+v", len(report.Items), report.Items)
+	}
+}
@@ -0,0 +1,395 @@
+package openstack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	pgrpc "github.com/cble-platform/cble-provider-grpc/pkg/provider"
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/routers"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/subnets"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrResourceMissing is returned when the Openstack object of a deployed resource no longer exists
+var ErrResourceMissing = errors.New("resource no longer exists")
+
+type DriftKind string
+
+const (
+	// The Openstack object of the resource no longer exists
+	DriftKindMissing DriftKind = "missing"
+	// A var has a different value (e.g. status, flavor or IP changed)
+	DriftKindChanged DriftKind = "changed"
+	// A var exists which wasn't saved (e.g. an interface attached out-of-band)
+	DriftKindAdded DriftKind = "added"
+	// A saved var no longer exists (e.g. an interface detached out-of-band)
+	DriftKindRemoved DriftKind = "removed"
+)
+
+type ResourceDrift struct {
+	// How the resource differs from its vars
+	Kind DriftKind `json:"kind"`
+	// The var which differs (omitted for missing resources)
+	Var string `json:"var,omitempty"`
+	// The saved value of the var
+	Saved string `json:"saved,omitempty"`
+	// The current value of the var
+	Current string `json:"current,omitempty"`
+}
+
+type RefreshReport struct {
+	// The key of the resource
+	Key string `json:"key"`
+	// The type of the resource
+	Type OpenstackResourceType `json:"type"`
+	// Every difference between the saved vars and the current state (empty if nothing drifted)
+	Drift []ResourceDrift `json:"drift"`
+	// The vars matching the current state (only the vars the provider keeps for itself if the resource is missing)
+	Vars map[string]string `json:"vars,omitempty"`
+	// The error encountered refreshing the resource
+	Error string `json:"error,omitempty"`
+}
+
+// Drifted checks if the resource differs from its saved vars
+func (r *RefreshReport) Drifted() bool {
+	return len(r.Drift) > 0
+}
+
+// RefreshResources re-reads the Openstack objects referenced by the vars of deployed resources (vars
+// are by resource key, as saved by CBLE) and reports how each resource has drifted from its vars.
+// If resources is nil, the resources are the objects they were deployed from (as saved in vars).
+// Resources without a saved ID haven't been deployed (or went missing) and are skipped. The vars of
+// the other resources are used as dependency vars (e.g. to match interfaces to the networks they are attached to)
+func (provider ProviderOpenstack) RefreshResources(ctx context.Context, resources []*pgrpc.Resource, vars map[string]map[string]string) ([]*RefreshReport, error) {
+	// Check if the provider has been configured
+	if CONFIG == nil {
+		return nil, fmt.Errorf("cannot refresh with unconfigured provider, please call Configure()")
+	}
+
+	// Generate authenticated client session
+	authClient, err := provider.newAuthClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to authenticate: %v", err)
+	}
+
+	// The saved vars of every resource are the dependency vars of the others
+	dependencyVars := make(map[string]*pgrpc.DependencyVars, len(vars))
+	for key, resourceVars := range vars {
+		dependencyVars[key] = &pgrpc.DependencyVars{Vars: resourceVars}
+	}
+	dependencyVars = withoutBookkeepingVars(dependencyVars)
+
+	if resources == nil {
+		resources = savedDeployedResources(vars)
+	}
+
+	reports := []*RefreshReport{}
+	for _, resource := range resources {
+		resourceVars, ok := vars[resource.Key]
+		if !ok || resourceVars["id"] == "" {
+			continue
+		}
+
+		object, err := parseObject(resource.Key, resource.Object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse object: %v", err)
+		}
+		// Data is retrieved again on every deploy
+		if object.Resource == nil {
+			continue
+		}
+
+		report := &RefreshReport{
+			Key:   resource.Key,
+			Type:  *object.Resource,
+			Drift: []ResourceDrift{},
+		}
+		reports = append(reports, report)
+
+		// Convert vars stored for a superseded version of the type (drift is reported against the converted vars)
+		resourceVars, err = migrateVars(object, resourceVars)
+		if err != nil {
+			report.Error = err.Error()
+			continue
+		}
+
+		if report.Vars, err = provider.refreshResource(ctx, authClient, resource, object, resourceVars, dependencyVars); err != nil {
+			if !errors.Is(err, ErrResourceMissing) {
+				report.Error = err.Error()
+				continue
+			}
+			report.Drift = append(report.Drift, ResourceDrift{Kind: DriftKindMissing})
+			// Keep the vars the provider keeps for itself (without an ID the resource is deployed again)
+			report.Vars = map[string]string{}
+			for k, v := range resourceVars {
+				if isBookkeepingVar(k) {
+					report.Vars[k] = v
+				}
+			}
+			continue
+		}
+		report.Drift = diffVars(resourceVars, report.Vars)
+	}
+
+	return reports, nil
+}
+
+// savedDeployedResources returns the resources of every object saved in vars by a deploy (in key order)
+func savedDeployedResources(vars map[string]map[string]string) []*pgrpc.Resource {
+	resources := []*pgrpc.Resource{}
+	for _, key := range sortedKeys(vars) {
+		if deployedObject, ok := savedDeployedObject(vars[key]); ok {
+			resources = append(resources, &pgrpc.Resource{
+				Id:     key,
+				Key:    key,
+				Object: []byte(deployedObject),
+			})
+		}
+	}
+	return resources
+}
+
+// refreshResource gets the current vars of a deployed resource from Openstack
+func (provider *ProviderOpenstack) refreshResource(ctx context.Context, authClient *gophercloud.ProviderClient, resource *pgrpc.Resource, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	handler, err := getResourceHandler(*object.Resource)
+	if err != nil {
+		return nil, err
+	}
+	return handler.Refresh(ctx, provider, authClient, resource, object, vars, dependencyVars)
+}
+
+// diffVars lists (in var order) every difference between the saved and current vars of a resource
+func diffVars(saved map[string]string, current map[string]string) []ResourceDrift {
+	drift := []ResourceDrift{}
+	for k, savedValue := range saved {
+		// The deployed object and type aren't Openstack state
+		if isBookkeepingVar(k) {
+			continue
+		}
+		currentValue, ok := current[k]
+		if !ok {
+			drift = append(drift, ResourceDrift{Kind: DriftKindRemoved, Var: k, Saved: savedValue})
+		} else if currentValue != savedValue {
+			drift = append(drift, ResourceDrift{Kind: DriftKindChanged, Var: k, Saved: savedValue, Current: currentValue})
+		}
+	}
+	for k, currentValue := range current {
+		if _, ok := saved[k]; !ok && !isBookkeepingVar(k) {
+			drift = append(drift, ResourceDrift{Kind: DriftKindAdded, Var: k, Current: currentValue})
+		}
+	}
+	sort.Slice(drift, func(i, j int) bool {
+		return drift[i].Var < drift[j].Var
+	})
+	return drift
+}
+
+// attachedNetworkKeys maps the IDs of the attached networks to their keys. Networks are found by the
+// ID in their own vars, falling back to the network of the port saved in vars (as "<network key>_port_id")
+// for networks without saved vars
+func attachedNetworkKeys(networkClient *gophercloud.ServiceClient, networkAttachments map[string]OpenstackNetworkAttachment, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	networkKeys := make(map[string]string)
+	for k := range networkAttachments {
+		if networkId, ok := dependencyVar(dependencyVars, k, "id"); ok {
+			networkKeys[networkId] = k
+			continue
+		}
+		osPortId, ok := vars[k+"_port_id"]
+		if !ok {
+			continue
+		}
+		port, err := ports.Get(networkClient, osPortId).Extract()
+		if isNotFound(err) {
+			// The interface was removed
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get port %s: %v", osPortId, err)
+		}
+		networkKeys[port.NetworkID] = k
+	}
+	return networkKeys, nil
+}
+
+func (provider *ProviderOpenstack) refreshHost(ctx context.Context, authClient *gophercloud.ProviderClient, resource *pgrpc.Resource, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	logrus.Debugf("Refreshing host \"%s\"", resource.Key)
+
+	// Get the Openstack server ID from vars
+	osServerId, ok := vars["id"]
+	if !ok {
+		return nil, fmt.Errorf("no ID found for resource")
+	}
+
+	// Generate the Compute V2 client
+	computeClient, err := openstack.NewComputeV2(authClient, gophercloud.EndpointOpts{
+		Region: CONFIG.RegionName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compute client: %v", err)
+	}
+
+	// Generate the Network V2 client
+	networkClient, err := openstack.NewNetworkV2(authClient, gophercloud.EndpointOpts{
+		Name:   "neutron",
+		Region: CONFIG.RegionName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create openstack network client: %v", err)
+	}
+
+	// Get the server details (including the availability zone)
+	server, err := getServerWithExt(computeClient, osServerId)
+	if isNotFound(err) {
+		return nil, ErrResourceMissing
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get openstack server: %v", err)
+	}
+
+	networkKeys, err := attachedNetworkKeys(networkClient, object.Host.Networks, vars, dependencyVars)
+	if err != nil {
+		return nil, err
+	}
+
+	// Start from the saved vars without the server details and addresses (so removed ones are dropped)
+	updatedVars := make(map[string]string)
+	for k, v := range vars {
+		updatedVars[k] = v
+	}
+	for _, k := range hostVarKeys {
+		// Boot from volume servers don't report an image, so keep the one used
+		if k != "image_id" {
+			delete(updatedVars, k)
+		}
+	}
+	for networkKey := range object.Host.Networks {
+		for _, k := range hostNetworkVarKeys {
+			delete(updatedVars, networkKey+"_"+k)
+		}
+	}
+
+	// Save the current server details and addresses into vars
+	if err = setHostVars(networkClient, server, networkKeys, updatedVars); err != nil {
+		return nil, fmt.Errorf("failed to get host addresses: %v", err)
+	}
+
+	return updatedVars, nil
+}
+
+func (provider *ProviderOpenstack) refreshNetwork(ctx context.Context, authClient *gophercloud.ProviderClient, resource *pgrpc.Resource, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	logrus.Debugf("Refreshing network \"%s\"", resource.Key)
+
+	// Get the Openstack network ID from vars
+	osNetworkId, ok := vars["id"]
+	if !ok {
+		return nil, fmt.Errorf("no ID found for resource")
+	}
+
+	// Generate the Network V2 client
+	networkClient, err := openstack.NewNetworkV2(authClient, gophercloud.EndpointOpts{
+		Name:   "neutron",
+		Region: CONFIG.RegionName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create openstack network client: %v", err)
+	}
+
+	_, err = networks.Get(networkClient, osNetworkId).Extract()
+	if isNotFound(err) {
+		return nil, ErrResourceMissing
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get network: %v", err)
+	}
+
+	// Start from the saved vars without the network and subnet details (so removed ones are dropped)
+	updatedVars := make(map[string]string)
+	for k, v := range vars {
+		updatedVars[k] = v
+	}
+	for _, k := range networkVarKeys {
+		delete(updatedVars, k)
+	}
+
+	// The subnet may have been deleted on its own
+	var subnet *subnets.Subnet
+	if osSubnetId, ok := vars["subnet_id"]; ok {
+		subnet, err = subnets.Get(networkClient, osSubnetId).Extract()
+		if isNotFound(err) {
+			subnet = nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get subnet: %v", err)
+		}
+	}
+
+	// Save the current network and subnet details into vars
+	if err = setNetworkVars(networkClient, osNetworkId, subnet, updatedVars); err != nil {
+		return nil, fmt.Errorf("failed to get network details: %v", err)
+	}
+
+	return updatedVars, nil
+}
+
+func (provider *ProviderOpenstack) refreshRouter(ctx context.Context, authClient *gophercloud.ProviderClient, resource *pgrpc.Resource, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	logrus.Debugf("Refreshing router \"%s\"", resource.Key)
+
+	// Get the Openstack router ID from vars
+	osRouterId, ok := vars["id"]
+	if !ok {
+		return nil, fmt.Errorf("no ID found for resource")
+	}
+
+	// Generate the Network V2 client
+	networkClient, err := openstack.NewNetworkV2(authClient, gophercloud.EndpointOpts{
+		Name:   "neutron",
+		Region: CONFIG.RegionName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create openstack network client: %v", err)
+	}
+
+	_, err = routers.Get(networkClient, osRouterId).Extract()
+	if isNotFound(err) {
+		return nil, ErrResourceMissing
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get router: %v", err)
+	}
+
+	networkKeys, err := attachedNetworkKeys(networkClient, object.Router.Networks, vars, dependencyVars)
+	if err != nil {
+		return nil, err
+	}
+
+	// Start from the saved vars without the router details, routes and interfaces (so removed ones are dropped)
+	updatedVars := make(map[string]string)
+	for k, v := range vars {
+		updatedVars[k] = v
+	}
+	for _, k := range routerVarKeys {
+		delete(updatedVars, k)
+	}
+	for k := range updatedVars {
+		if strings.HasPrefix(k, "route_") {
+			delete(updatedVars, k)
+		}
+	}
+	for networkKey := range object.Router.Networks {
+		delete(updatedVars, networkKey+"_port_id")
+		delete(updatedVars, networkKey+"_ip")
+	}
+
+	// Save the current router details and interface addresses into vars
+	if err = setRouterVars(networkClient, osRouterId, networkKeys, updatedVars); err != nil {
+		return nil, fmt.Errorf("failed to get router details: %v", err)
+	}
+
+	return updatedVars, nil
+}
@@ -0,0 +1,106 @@
+package openstack
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	pgrpc "github.com/cble-platform/cble-provider-grpc/pkg/provider"
+)
+
+func TestAttachedNetworkKeys(t *testing.T) {
+	networkClient := newTestServiceClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/ports/net2-port-id":
+			w.Write([]byte(`{"port": {"id": "net2-port-id", "network_id": "net2-id"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	attachments := map[string]OpenstackNetworkAttachment{
+		"net1": {DHCP: true},
+		"net2": {DHCP: true},
+		"net3": {DHCP: true},
+	}
+	vars := map[string]string{
+		// The saved port of net1 was replaced out-of-band (so no longer exists)
+		"net1_port_id": "replaced-port-id",
+		// net2 has no saved vars of its own
+		"net2_port_id": "net2-port-id",
+		// The saved port of net3 was removed
+		"net3_port_id": "removed-port-id",
+	}
+	dependencyVars := map[string]*pgrpc.DependencyVars{
+		"net1": {Vars: map[string]string{"id": "net1-id"}},
+	}
+
+	got, err := attachedNetworkKeys(networkClient, attachments, vars, dependencyVars)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{"net1-id": "net1", "net2-id": "net2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDiffVarsIgnoresBookkeepingVars(t *testing.T) {
+	saved := map[string]string{
+		"id":              "server-id",
+		"status":          "ACTIVE",
+		deployedObjectVar: "resource: openstack.v1.host",
+		typeVar:           "openstack.v1.host",
+	}
+	current := map[string]string{
+		"id":     "server-id",
+		"status": "SHUTOFF",
+	}
+	want := []ResourceDrift{{Kind: DriftKindChanged, Var: "status", Saved: "ACTIVE", Current: "SHUTOFF"}}
+	if got := diffVars(saved, current); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRefreshResourcesFromSavedObjects(t *testing.T) {
+	withFakeCloud(t, func(w http.ResponseWriter, r *http.Request) {
+		// Every network has been deleted out-of-band
+		http.NotFound(w, r)
+	})
+
+	networkObject := "resource: openstack.v1.network\nconfig:\n  subnet: 10.0.0.0/24\n"
+	vars := map[string]map[string]string{
+		"net1": {
+			"id":              "net1-id",
+			"subnet_id":       "net1-subnet-id",
+			deployedObjectVar: networkObject,
+			typeVar:           "openstack.v1.network",
+		},
+		// Already refreshed as missing
+		"net2": {
+			deployedObjectVar: networkObject,
+			typeVar:           "openstack.v1.network",
+		},
+		// Not deployed
+		"net3": {},
+	}
+
+	reports, err := ProviderOpenstack{}.RefreshResources(context.Background(), nil, vars)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []*RefreshReport{{
+		Key:   "net1",
+		Type:  OpenstackResourceTypeNetwork,
+		Drift: []ResourceDrift{{Kind: DriftKindMissing}},
+		Vars: map[string]string{
+			deployedObjectVar: networkObject,
+			typeVar:           "openstack.v1.network",
+		},
+	}}
+	if !reflect.DeepEqual(reports, want) {
+		t.Errorf("got %+v, want %+v", reports, want)
+	}
+}
@@ -0,0 +1,241 @@
+package openstack
+
+import (
+	_ "embed"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/netip"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The struct definitions, embedded so field descriptions come straight from their doc comments
+//
+//go:embed structs.go
+var structsSource string
+
+// Schemas of types which are decoded from strings
+var (
+	// Formats are only annotations by default, so both branches can match (oneOf would reject every IP)
+	ipSchema = map[string]any{
+		"type":  "string",
+		"anyOf": []any{map[string]any{"format": "ipv4"}, map[string]any{"format": "ipv6"}},
+	}
+	prefixSchema = map[string]any{
+		"type":    "string",
+		"pattern": `^[0-9a-fA-F.:]+/[0-9]{1,3}$`,
+	}
+	resolversSchema = map[string]any{
+		"oneOf": []any{
+			map[string]any{"type": "array", "items": ipSchema},
+			map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "array", "items": ipSchema}},
+		},
+	}
+	durationSchema = map[string]any{
+		"type":    "string",
+		"pattern": `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`,
+	}
+)
+
+var (
+	fieldDescriptionsOnce sync.Once
+	fieldDescriptions     map[string]string
+)
+
+// loadFieldDescriptions parses the doc comment of every struct field into a "Type.Field" map
+func loadFieldDescriptions() map[string]string {
+	fieldDescriptionsOnce.Do(func() {
+		fieldDescriptions = make(map[string]string)
+		file, err := parser.ParseFile(token.NewFileSet(), "structs.go", structsSource, parser.ParseComments)
+		if err != nil {
+			return
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			typeSpec, ok := n.(*ast.TypeSpec)
+			if !ok {
+				return true
+			}
+			structType, ok := typeSpec.Type.(*ast.StructType)
+			if !ok {
+				return true
+			}
+			for _, field := range structType.Fields.List {
+				if field.Doc == nil {
+					continue
+				}
+				for _, name := range field.Names {
+					fieldDescriptions[typeSpec.Name.Name+"."+name.Name] = strings.TrimSpace(strings.ReplaceAll(field.Doc.Text(), "\n", " "))
+				}
+			}
+			return false
+		})
+	})
+	return fieldDescriptions
+}
+
+// typeSchema generates the JSON schema of a type from its YAML decoding. If forData is set, no
+// fields are required (data configs are lookups)
+func typeSchema(t reflect.Type, forData bool) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	// Types decoded from strings
+	switch t {
+	case reflect.TypeOf(netip.Addr{}):
+		return copySchema(ipSchema)
+	case reflect.TypeOf(OpenstackNetworkResolvers{}):
+		return copySchema(resolversSchema)
+	case reflect.TypeOf(netip.Prefix{}):
+		return copySchema(prefixSchema)
+	case reflect.TypeOf(time.Duration(0)):
+		return copySchema(durationSchema)
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice:
+		// Byte slices are decoded from strings
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string"}
+		}
+		return map[string]any{
+			"type":  "array",
+			"items": typeSchema(t.Elem(), forData),
+		}
+	case reflect.Map:
+		return map[string]any{
+			"type":                 "object",
+			"additionalProperties": typeSchema(t.Elem(), forData),
+		}
+	case reflect.Struct:
+		return structSchema(t, forData)
+	default:
+		return map[string]any{}
+	}
+}
+
+// structSchema generates the JSON schema of a struct from its YAML tags
+func structSchema(t reflect.Type, forData bool) map[string]any {
+	descriptions := loadFieldDescriptions()
+	properties := map[string]any{}
+	required := []string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("yaml"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		omitEmpty := false
+		for _, option := range tag[1:] {
+			if option == "omitempty" {
+				omitEmpty = true
+			}
+		}
+
+		fieldSchema := typeSchema(field.Type, forData)
+		if description, ok := descriptions[t.Name()+"."+field.Name]; ok {
+			fieldSchema["description"] = description
+		}
+		properties[name] = fieldSchema
+
+		// Fields which are always encoded (and aren't optional pointers) must be set
+		if !forData && !omitEmpty && field.Type.Kind() != reflect.Pointer {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// copySchema makes a shallow copy of a shared schema (so descriptions can be added)
+func copySchema(schema map[string]any) map[string]any {
+	copied := make(map[string]any, len(schema))
+	for k, v := range schema {
+		copied[k] = v
+	}
+	return copied
+}
+
+// ConfigSchema generates the JSON schema of the config of a blueprint object type (as a resource or data)
+func ConfigSchema(objectType OpenstackResourceType, forData bool) (map[string]any, error) {
+	handler, err := getResourceHandler(objectType)
+	if err != nil {
+		return nil, err
+	}
+	if handler.DataOnly() && !forData {
+		return nil, fmt.Errorf("type \"%s\" can only be used as data", objectType)
+	}
+	schema := typeSchema(handler.ConfigType(), forData)
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = string(objectType)
+	return schema, nil
+}
+
+// BlueprintSchema generates the JSON schema of a whole blueprint (a version and a map of objects)
+func BlueprintSchema() map[string]any {
+	defs := map[string]any{}
+	objectRefs := []any{}
+
+	for _, t := range registeredResourceTypes() {
+		kinds := []string{"resource", "data"}
+		if resourceHandlers[t].DataOnly() {
+			kinds = []string{"data"}
+		}
+		for _, kind := range kinds {
+			config, _ := ConfigSchema(t, kind == "data")
+			delete(config, "$schema")
+			defName := fmt.Sprintf("%s.%s.%s", t.Version(), t.ShortName(), kind)
+			defs[defName] = map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					kind:         map[string]any{"const": string(t)},
+					"depends_on": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+					"config":     config,
+				},
+				"required":             []string{kind, "config"},
+				"additionalProperties": false,
+			}
+			objectRefs = append(objectRefs, map[string]any{"$ref": "#/$defs/" + defName})
+		}
+	}
+
+	return map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title":   "Openstack CBLE blueprint",
+		"type":    "object",
+		"properties": map[string]any{
+			"version": map[string]any{"type": "string"},
+		},
+		"additionalProperties": map[string]any{"oneOf": objectRefs},
+		"$defs":                defs,
+	}
+}
@@ -0,0 +1,116 @@
+package openstack
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden schema files")
+
+// checkGolden compares a schema against its committed golden file (rewriting it with -update)
+func checkGolden(t *testing.T, name string, schema map[string]any) {
+	t.Helper()
+	got, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		t.Fatalf("failed to marshal schema: %v", err)
+	}
+	got = append(got, '\n')
+
+	path := filepath.Join("testdata", "schema", name+".json")
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run go test ./openstack -run Schema -update): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("schema %s drifted from %s (run go test ./openstack -run Schema -update and review the diff)", name, path)
+	}
+}
+
+func TestBlueprintSchemaGolden(t *testing.T) {
+	checkGolden(t, "blueprint", BlueprintSchema())
+}
+
+func TestConfigSchemaGolden(t *testing.T) {
+	for _, objectType := range registeredResourceTypes() {
+		for _, forData := range []bool{false, true} {
+			if !forData && resourceHandlers[objectType].DataOnly() {
+				continue
+			}
+			name := string(objectType)
+			if forData {
+				name += ".data"
+			}
+			t.Run(name, func(t *testing.T) {
+				schema, err := ConfigSchema(objectType, forData)
+				if err != nil {
+					t.Fatalf("failed to generate schema: %v", err)
+				}
+				checkGolden(t, name, schema)
+			})
+		}
+	}
+}
+
+func TestConfigSchemaCoversFields(t *testing.T) {
+	for _, objectType := range registeredResourceTypes() {
+		schema, err := ConfigSchema(objectType, true)
+		if err != nil {
+			t.Fatalf("failed to generate schema of %s: %v", objectType, err)
+		}
+		checkSchemaFields(t, string(objectType), resourceHandlers[objectType].ConfigType(), schema)
+	}
+}
+
+// checkSchemaFields checks every yaml-tagged field of a struct (and the structs it contains) has a
+// property in its schema
+func checkSchemaFields(t *testing.T, path string, typ reflect.Type, schema map[string]any) {
+	t.Helper()
+	for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Map {
+		switch {
+		case typ.Kind() == reflect.Slice && schema["items"] != nil:
+			schema, _ = schema["items"].(map[string]any)
+		case typ.Kind() == reflect.Map && schema["additionalProperties"] != nil:
+			schema, _ = schema["additionalProperties"].(map[string]any)
+		}
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || schema["properties"] == nil {
+		return
+	}
+	properties := schema["properties"].(map[string]any)
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag, ok := field.Tag.Lookup("yaml")
+		if !ok || !field.IsExported() {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		property, ok := properties[name].(map[string]any)
+		if !ok {
+			t.Errorf("%s: field %s.%s (yaml %q) has no schema property", path, typ.Name(), field.Name, name)
+			continue
+		}
+		checkSchemaFields(t, path+"."+name, field.Type, property)
+	}
+}
@@ -2,8 +2,8 @@ package openstack
 
 import (
 	"fmt"
-	"net"
 	"net/netip"
+	"reflect"
 
 	"github.com/cble-platform/cble/backend/engine/models"
 	"gopkg.in/yaml.v3"
@@ -15,6 +15,11 @@ const (
 	OpenstackResourceTypeHost    OpenstackResourceType = "openstack.v1.host"
 	OpenstackResourceTypeNetwork OpenstackResourceType = "openstack.v1.network"
 	OpenstackResourceTypeRouter  OpenstackResourceType = "openstack.v1.router"
+	// Data only types
+	OpenstackResourceTypeImage            OpenstackResourceType = "openstack.v1.image"
+	OpenstackResourceTypeFlavor           OpenstackResourceType = "openstack.v1.flavor"
+	OpenstackResourceTypeAvailabilityZone OpenstackResourceType = "openstack.v1.availability_zone"
+	OpenstackResourceTypeSecurityGroup    OpenstackResourceType = "openstack.v1.security_group"
 )
 
 type OpenstackBlueprint struct {
@@ -27,6 +32,52 @@ type OpenstackBlueprint struct {
 	Routers  map[string]OpenstackRouter  `yaml:"-"`
 }
 
+func (b *OpenstackBlueprint) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind != yaml.MappingNode {
+		return newParseError(n, "", fmt.Errorf("blueprint must be a map of objects"))
+	}
+
+	// Decode the standard blueprint values
+	if err := decodeNode(n, "", &b.Blueprint); err != nil {
+		return err
+	}
+
+	// Decode every other value as an object (so problems can be tagged with the object key)
+	b.Objects = make(map[string]OpenstackObject)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key := n.Content[i].Value
+		if _, ok := yamlFieldType(reflect.TypeOf(models.Blueprint{}), key); ok {
+			continue
+		}
+		var object OpenstackObject
+		if err := n.Content[i+1].Decode(&object); err != nil {
+			return withParseKey(key, n.Content[i+1], err)
+		}
+		b.Objects[key] = object
+	}
+
+	b.populateTypedMaps()
+	return nil
+}
+
+// populateTypedMaps fills the host, network and router maps from the parsed objects
+func (b *OpenstackBlueprint) populateTypedMaps() {
+	b.Hosts = make(map[string]OpenstackHost)
+	b.Networks = make(map[string]OpenstackNetwork)
+	b.Routers = make(map[string]OpenstackRouter)
+	for k, o := range b.Objects {
+		if o.Host != nil {
+			b.Hosts[k] = *o.Host
+		}
+		if o.Network != nil {
+			b.Networks[k] = *o.Network
+		}
+		if o.Router != nil {
+			b.Routers[k] = *o.Router
+		}
+	}
+}
+
 type OpenstackObject struct {
 	// Inherit standard object values
 	models.Object `yaml:",inline"`
@@ -36,6 +87,16 @@ type OpenstackObject struct {
 	Host     *OpenstackHost         `yaml:"-"`
 	Network  *OpenstackNetwork      `yaml:"-"`
 	Router   *OpenstackRouter       `yaml:"-"`
+	// Openstack specific data only values
+	Image            *OpenstackImage            `yaml:"-"`
+	Flavor           *OpenstackFlavor           `yaml:"-"`
+	AvailabilityZone *OpenstackAvailabilityZone `yaml:"-"`
+	SecurityGroup    *OpenstackSecurityGroup    `yaml:"-"`
+	// The superseded versions of the type the object was converted from (in order)
+	MigratedFrom []OpenstackResourceType `yaml:"-"`
+	// Location of the object in the YAML it was parsed from
+	Line   int `yaml:"-"`
+	Column int `yaml:"-"`
 }
 
 func (o *OpenstackObject) UnmarshalYAML(n *yaml.Node) error {
@@ -45,34 +106,55 @@ func (o *OpenstackObject) UnmarshalYAML(n *yaml.Node) error {
 	}
 
 	obj := &T{O: (*O)(o)}
-	if err := n.Decode(obj); err != nil {
+	if err := decodeNode(n, "", obj); err != nil {
 		return err
 	}
+	o.Line = n.Line
+	o.Column = n.Column
 
 	// Convert resource string into openstack resource type
 	var t OpenstackResourceType
+	typeField := ""
 	if o.Object.Resource != nil {
 		t = OpenstackResourceType(*o.Object.Resource)
 		o.Resource = &t
+		typeField = "resource"
 	} else if o.Object.Data != nil {
 		t = OpenstackResourceType(*o.Object.Data)
 		o.Data = &t
+		typeField = "data"
+	}
+	typeNode := mappingValue(n, typeField)
+	if typeNode == nil {
+		typeNode = n
 	}
 
-	// Marshall the various resource types
-	switch t {
-	case OpenstackResourceTypeHost:
-		o.Host = new(OpenstackHost)
-		return obj.Config.Decode(o.Host)
-	case OpenstackResourceTypeNetwork:
-		o.Network = new(OpenstackNetwork)
-		return obj.Config.Decode(o.Network)
-	case OpenstackResourceTypeRouter:
-		o.Router = new(OpenstackRouter)
-		return obj.Config.Decode(o.Router)
-	default:
-		return fmt.Errorf("unknown resource type \"%s\"", t)
+	// Problems in the config are located within the config node
+	configNode := mappingValue(n, "config")
+	if configNode == nil {
+		configNode = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: n.Line, Column: n.Column}
+	}
+
+	// Decode the config with the handler of the type
+	handler, err := getResourceHandler(t)
+	if err != nil {
+		return newParseError(typeNode, typeField, err)
 	}
+
+	// Data only types can't be used as resources
+	if o.Resource != nil && handler.DataOnly() {
+		return newParseError(typeNode, "resource", fmt.Errorf("type \"%s\" can only be used as data", t))
+	}
+
+	if err := handler.Decode(configNode, o); err != nil {
+		return err
+	}
+
+	// Convert objects of superseded versions to the newest version of the type
+	if err := migrateObject(o); err != nil {
+		return newParseError(typeNode, typeField, err)
+	}
+	return nil
 }
 
 type OpenstackHost struct {
@@ -84,16 +166,20 @@ type OpenstackHost struct {
 	Description *string `yaml:"description,omitempty"`
 	// Hostname of the host
 	Hostname string `yaml:"hostname,omitempty"`
-	// Image of the host
+	// Image of the host (ID, name or key of an image data object)
 	Image string `yaml:"image,omitempty"`
-	// Flavor of the host
+	// Flavor of the host (ID, name or key of a flavor data object)
 	Flavor string `yaml:"flavor,omitempty"`
+	// Availability zone of the host (name or key of an availability zone data object)
+	AvailabilityZone string `yaml:"availability_zone,omitempty"`
 	// Disk size of the host (in GB)
 	DiskSize int `yaml:"disk_size,omitempty"`
 	// Networks to attach this host to
 	Networks map[string]OpenstackNetworkAttachment `yaml:"networks,omitempty"`
 	// Any userdata to pass to created instance
 	UserData []byte `yaml:"user_data,omitempty"`
+	// Filters used to look up the host (data only)
+	Filter *OpenstackDataFilter `yaml:"filter,omitempty"`
 }
 
 type OpenstackNetworkAttachment struct {
@@ -116,8 +202,66 @@ type OpenstackNetwork struct {
 	Gateway *netip.Addr `yaml:"gateway,omitempty"`
 	// DHCP ranges for the network (omit to disable DHCP)
 	DHCP []OpenstackNetworkDHCP `yaml:"dhcp,omitempty"`
-	// DNS servers for the network (omit to disable DNS)
-	Resolvers []net.Addr `yaml:"resolvers,omitempty"`
+	// DNS servers for the network, as a list for every subnet or a map of subnet CIDR to list (omit to disable DNS)
+	Resolvers OpenstackNetworkResolvers `yaml:"resolvers,omitempty"`
+	// Provider network settings to bind the network to a physical segment (requires admin)
+	Provider *OpenstackNetworkProvider `yaml:"provider,omitempty"`
+	// Should the network be shared with all projects (requires admin)
+	Shared *bool `yaml:"shared,omitempty"`
+	// Should the network be usable as a router external gateway (requires admin)
+	External *bool `yaml:"external,omitempty"`
+	// Should destroy remove router interfaces and stray ports on the network before deleting it
+	CascadeDestroy bool `yaml:"cascade_destroy,omitempty"`
+	// Filters used to look up the network (data only)
+	Filter *OpenstackDataFilter `yaml:"filter,omitempty"`
+}
+
+type OpenstackNetworkResolvers struct {
+	// DNS servers for every subnet of the network
+	All []netip.Addr
+	// DNS servers for specific subnets of the network (by CIDR)
+	Subnets map[netip.Prefix][]netip.Addr
+}
+
+func (r *OpenstackNetworkResolvers) UnmarshalYAML(n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.SequenceNode:
+		return decodeNode(n, "", &r.All)
+	case yaml.MappingNode:
+		return decodeNode(n, "", &r.Subnets)
+	default:
+		return newParseError(n, "", fmt.Errorf("resolvers must be a list of IP addresses or a map of subnet CIDR to IP addresses"))
+	}
+}
+
+func (r OpenstackNetworkResolvers) MarshalYAML() (interface{}, error) {
+	if len(r.Subnets) > 0 {
+		return r.Subnets, nil
+	}
+	return r.All, nil
+}
+
+func (r OpenstackNetworkResolvers) IsZero() bool {
+	return len(r.All) == 0 && len(r.Subnets) == 0
+}
+
+// ForSubnet returns the DNS servers of a subnet of the network
+func (r OpenstackNetworkResolvers) ForSubnet(subnet netip.Prefix) []netip.Addr {
+	for resolverSubnet, resolvers := range r.Subnets {
+		if resolverSubnet.Masked() == subnet.Masked() {
+			return resolvers
+		}
+	}
+	return r.All
+}
+
+type OpenstackNetworkProvider struct {
+	// The type of the network (e.g. flat, vlan, vxlan)
+	NetworkType string `yaml:"network_type"`
+	// The name of the physical network the network is bound to (required for flat and vlan)
+	PhysicalNetwork string `yaml:"physical_network,omitempty"`
+	// The VLAN ID or VXLAN VNI of the network (omit for flat networks)
+	SegmentationID *int `yaml:"segmentation_id,omitempty"`
 }
 
 type OpenstackNetworkDHCP struct {
@@ -134,8 +278,119 @@ type OpenstackRouter struct {
 	Name *string `yaml:"name,omitempty"`
 	// Openstack router description
 	Description *string `yaml:"description,omitempty"`
-	// The ID or Name of the external Openstack network to attach this router to
-	ExternalNetwork string `yaml:"external_network"`
-	// Networks to attach this host to
+	// The ID or Name of the external Openstack network to attach this router to (omit for an internal-only router)
+	ExternalNetwork string `yaml:"external_network,omitempty"`
+	// Networks to attach this router to (omit both dhcp and ip to use the subnet gateway IP)
 	Networks map[string]OpenstackNetworkAttachment `yaml:"networks"`
+	// Should SNAT be enabled on the external gateway (defaults to enabled)
+	EnableSNAT *bool `yaml:"enable_snat,omitempty"`
+	// Specific IPs to request on the external network
+	ExternalFixedIPs []OpenstackRouterExternalIP `yaml:"external_fixed_ips,omitempty"`
+	// Static routes to add to the router
+	Routes []OpenstackRouterRoute `yaml:"routes,omitempty"`
+	// Should the router be distributed (requires admin)
+	Distributed *bool `yaml:"distributed,omitempty"`
+	// Should the router be highly available (requires admin)
+	HA *bool `yaml:"ha,omitempty"`
+	// Filters used to look up the router (data only)
+	Filter *OpenstackDataFilter `yaml:"filter,omitempty"`
+}
+
+type OpenstackRouterExternalIP struct {
+	// The IP address to request on the external network (omit for any address on the subnet)
+	IP *netip.Addr `yaml:"ip,omitempty"`
+	// The ID of the external subnet to request the IP on (omit to find the subnet containing the IP)
+	SubnetID string `yaml:"subnet_id,omitempty"`
+}
+
+type OpenstackRouterRoute struct {
+	// The destination CIDR of the route
+	Destination netip.Prefix `yaml:"destination"`
+	// The next hop IP address of the route
+	NextHop netip.Addr `yaml:"nexthop"`
+}
+
+type OpenstackDataFilter struct {
+	// Tags the object must have (all must match)
+	Tags []string `yaml:"tags,omitempty"`
+	// Metadata key/values the object must have (hosts only)
+	Metadata map[string]string `yaml:"metadata,omitempty"`
+	// ID of the project the object belongs to
+	ProjectID string `yaml:"project_id,omitempty"`
+	// Status of the object (e.g. ACTIVE)
+	Status string `yaml:"status,omitempty"`
+	// An IP address the object must have (hosts only)
+	IP *netip.Addr `yaml:"ip,omitempty"`
+}
+
+type OpenstackImage struct {
+	// Glance image id
+	ID *string `yaml:"id,omitempty"`
+	// Exact name of the image
+	Name *string `yaml:"name,omitempty"`
+	// Regex the image name must match
+	NameRegex *string `yaml:"name_regex,omitempty"`
+	// Tags the image must have (all must match)
+	Tags []string `yaml:"tags,omitempty"`
+	// Visibility of the image (public, private, shared or community)
+	Visibility string `yaml:"visibility,omitempty"`
+	// Status of the image (defaults to active)
+	Status string `yaml:"status,omitempty"`
+	// Properties the image must have (e.g. os_distro: ubuntu)
+	Properties map[string]string `yaml:"properties,omitempty"`
+	// Attribute to sort matches by, the first match is selected (name, created_at, updated_at, size, min_disk or min_ram)
+	SortKey string `yaml:"sort_key,omitempty"`
+	// Direction to sort matches in (asc or desc, defaults to asc)
+	SortDir string `yaml:"sort_dir,omitempty"`
+}
+
+type OpenstackFlavor struct {
+	// Nova flavor id
+	ID *string `yaml:"id,omitempty"`
+	// Exact name of the flavor
+	Name *string `yaml:"name,omitempty"`
+	// Regex the flavor name must match
+	NameRegex *string `yaml:"name_regex,omitempty"`
+	// Minimum number of vCPUs
+	MinVCPUs int `yaml:"min_vcpus,omitempty"`
+	// Maximum number of vCPUs
+	MaxVCPUs int `yaml:"max_vcpus,omitempty"`
+	// Minimum RAM (in MiB)
+	MinRAM int `yaml:"min_ram,omitempty"`
+	// Maximum RAM (in MiB)
+	MaxRAM int `yaml:"max_ram,omitempty"`
+	// Minimum root disk (in GB)
+	MinDisk int `yaml:"min_disk,omitempty"`
+	// Maximum root disk (in GB)
+	MaxDisk int `yaml:"max_disk,omitempty"`
+	// Should the flavor be public
+	IsPublic *bool `yaml:"is_public,omitempty"`
+	// Attribute to sort matches by, the first match is selected (name, vcpus, ram or disk)
+	SortKey string `yaml:"sort_key,omitempty"`
+	// Direction to sort matches in (asc or desc, defaults to asc)
+	SortDir string `yaml:"sort_dir,omitempty"`
+}
+
+type OpenstackAvailabilityZone struct {
+	// Exact name of the availability zone
+	Name *string `yaml:"name,omitempty"`
+	// Regex the availability zone name must match
+	NameRegex *string `yaml:"name_regex,omitempty"`
+	// Should the availability zone be available (defaults to true)
+	Available *bool `yaml:"available,omitempty"`
+	// Direction to sort matches by name in (asc or desc), the first match is selected
+	SortDir string `yaml:"sort_dir,omitempty"`
+}
+
+type OpenstackSecurityGroup struct {
+	// Neutron security group id
+	ID *string `yaml:"id,omitempty"`
+	// Exact name of the security group
+	Name *string `yaml:"name,omitempty"`
+	// Regex the security group name must match
+	NameRegex *string `yaml:"name_regex,omitempty"`
+	// Tags the security group must have (all must match)
+	Tags []string `yaml:"tags,omitempty"`
+	// Project the security group belongs to
+	ProjectID string `yaml:"project_id,omitempty"`
 }
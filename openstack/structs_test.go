@@ -0,0 +1,193 @@
+package openstack
+
+import (
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestOpenstackNetworkUnmarshal(t *testing.T) {
+	segmentationId := 100
+	enabled := true
+	disabled := false
+	gateway := netip.MustParseAddr("10.0.0.1")
+
+	tests := []struct {
+		name string
+		in   string
+		want OpenstackNetwork
+		// Substring of the expected error (empty if the network must decode)
+		wantErr string
+	}{
+		{
+			name: "subnet only",
+			in:   "subnet: 10.0.0.0/24",
+			want: OpenstackNetwork{Subnet: netip.MustParsePrefix("10.0.0.0/24")},
+		},
+		{
+			name: "every field",
+			in: `
+id: net-id
+name: lab
+description: Lab network
+subnet: 10.0.0.0/24
+gateway: 10.0.0.1
+dhcp:
+  - start: 10.0.0.100
+    end: 10.0.0.200
+resolvers: [1.1.1.1, 2606:4700:4700::1111]
+provider:
+  network_type: vlan
+  physical_network: physnet1
+  segmentation_id: 100
+shared: true
+external: false
+cascade_destroy: true
+filter:
+  tags: [lab]
+  project_id: abc
+  status: ACTIVE
+`,
+			want: OpenstackNetwork{
+				ID:          strPtr("net-id"),
+				Name:        strPtr("lab"),
+				Description: strPtr("Lab network"),
+				Subnet:      netip.MustParsePrefix("10.0.0.0/24"),
+				Gateway:     &gateway,
+				DHCP: []OpenstackNetworkDHCP{
+					{Start: netip.MustParseAddr("10.0.0.100"), End: netip.MustParseAddr("10.0.0.200")},
+				},
+				Resolvers: OpenstackNetworkResolvers{
+					All: []netip.Addr{netip.MustParseAddr("1.1.1.1"), netip.MustParseAddr("2606:4700:4700::1111")},
+				},
+				Provider: &OpenstackNetworkProvider{
+					NetworkType:     "vlan",
+					PhysicalNetwork: "physnet1",
+					SegmentationID:  &segmentationId,
+				},
+				Shared:         &enabled,
+				External:       &disabled,
+				CascadeDestroy: true,
+				Filter: &OpenstackDataFilter{
+					Tags:      []string{"lab"},
+					ProjectID: "abc",
+					Status:    "ACTIVE",
+				},
+			},
+		},
+		{
+			name: "ipv6 subnet",
+			in:   "subnet: fd00::/64\ngateway: fd00::1",
+			want: OpenstackNetwork{
+				Subnet:  netip.MustParsePrefix("fd00::/64"),
+				Gateway: addrPtr("fd00::1"),
+			},
+		},
+		{
+			name: "per-subnet resolvers",
+			in: `
+subnet: 10.0.0.0/24
+resolvers:
+  10.0.0.0/24: [10.0.0.53]
+  fd00::/64: [fd00::53, fd00::54]
+`,
+			want: OpenstackNetwork{
+				Subnet: netip.MustParsePrefix("10.0.0.0/24"),
+				Resolvers: OpenstackNetworkResolvers{
+					Subnets: map[netip.Prefix][]netip.Addr{
+						netip.MustParsePrefix("10.0.0.0/24"): {netip.MustParseAddr("10.0.0.53")},
+						netip.MustParsePrefix("fd00::/64"):   {netip.MustParseAddr("fd00::53"), netip.MustParseAddr("fd00::54")},
+					},
+				},
+			},
+		},
+		{
+			name:    "bad gateway ip",
+			in:      "subnet: 10.0.0.0/24\ngateway: 10.0.0.300",
+			wantErr: "gateway",
+		},
+		{
+			name:    "bad resolver ip",
+			in:      "subnet: 10.0.0.0/24\nresolvers: [1.1.1]",
+			wantErr: "resolvers[0]",
+		},
+		{
+			name:    "bad dhcp ip",
+			in:      "subnet: 10.0.0.0/24\ndhcp: [{start: 10.0.0.10, end: nope}]",
+			wantErr: "dhcp[0].end",
+		},
+		{
+			name:    "bad subnet cidr",
+			in:      "subnet: 10.0.0.0/33",
+			wantErr: "subnet",
+		},
+		{
+			name:    "subnet without prefix length",
+			in:      "subnet: 10.0.0.0",
+			wantErr: "subnet",
+		},
+		{
+			name:    "bad resolver subnet cidr",
+			in:      "subnet: 10.0.0.0/24\nresolvers: {10.0.0.0: [1.1.1.1]}",
+			wantErr: "resolvers",
+		},
+		{
+			name:    "scalar resolvers",
+			in:      "subnet: 10.0.0.0/24\nresolvers: 1.1.1.1",
+			wantErr: "resolvers must be a list of IP addresses or a map of subnet CIDR to IP addresses",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var root yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.in), &root); err != nil {
+				t.Fatalf("invalid test yaml: %v", err)
+			}
+			var got OpenstackNetwork
+			err := decodeNode(root.Content[0], "", &got)
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatalf("expected error containing %q, got %+v", tt.wantErr, got)
+				}
+				if !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %q", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenstackNetworkResolversForSubnet(t *testing.T) {
+	resolvers := OpenstackNetworkResolvers{
+		All: []netip.Addr{netip.MustParseAddr("1.1.1.1")},
+		Subnets: map[netip.Prefix][]netip.Addr{
+			netip.MustParsePrefix("fd00::/64"): {netip.MustParseAddr("fd00::53")},
+		},
+	}
+	if got := resolvers.ForSubnet(netip.MustParsePrefix("fd00::1/64")); !reflect.DeepEqual(got, []netip.Addr{netip.MustParseAddr("fd00::53")}) {
+		t.Errorf("got %v for the v6 subnet", got)
+	}
+	if got := resolvers.ForSubnet(netip.MustParsePrefix("10.0.0.0/24")); !reflect.DeepEqual(got, resolvers.All) {
+		t.Errorf("got %v for a subnet without its own resolvers", got)
+	}
+}
+
+func addrPtr(s string) *netip.Addr {
+	addr := netip.MustParseAddr(s)
+	return &addr
+}
+
+func strPtr(s string) *string {
+	return &s
+}
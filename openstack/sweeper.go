@@ -0,0 +1,474 @@
+package openstack
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	"github.com/gophercloud/gophercloud/openstack/blockstorage/v3/volumes"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/routers"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/subnets"
+	"github.com/gophercloud/gophercloud/pagination"
+	"github.com/sirupsen/logrus"
+)
+
+type SweeperConfig struct {
+	// How often to sweep the project (omit to disable scheduled sweeps)
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// File containing the IDs of all known CBLE deployments (one per line)
+	KnownDeploymentsFile string `yaml:"known_deployments_file,omitempty"`
+	// Should orphans be deleted (otherwise only reported)
+	Delete bool `yaml:"delete,omitempty"`
+	// Minimum age of an object before it is considered an orphan (defaults to DefaultSweepMinAge)
+	MinAge time.Duration `yaml:"min_age,omitempty"`
+	// Also match untagged objects by the default name prefix (first 8 bytes of deployment ID)
+	MatchNamePrefix bool `yaml:"match_name_prefix,omitempty"`
+}
+
+type SweepOptions struct {
+	// The IDs of all known CBLE deployments (objects of any other deployment are orphans)
+	KnownDeployments map[string]bool
+	// Should orphans be deleted (otherwise only reported)
+	Delete bool
+	// Minimum age of an object before it is considered an orphan (only for objects with a creation time)
+	MinAge time.Duration
+	// Also match untagged objects by the default name prefix (first 8 bytes of deployment ID)
+	MatchNamePrefix bool
+}
+
+type SweptObjectType string
+
+// Object types in the order they are deleted
+const (
+	SweptObjectTypeServer          SweptObjectType = "server"
+	SweptObjectTypeVolume          SweptObjectType = "volume"
+	SweptObjectTypeRouterInterface SweptObjectType = "router_interface"
+	SweptObjectTypePort            SweptObjectType = "port"
+	SweptObjectTypeRouter          SweptObjectType = "router"
+	SweptObjectTypeSubnet          SweptObjectType = "subnet"
+	SweptObjectTypeNetwork         SweptObjectType = "network"
+)
+
+type SweptObject struct {
+	// The type of the Openstack object
+	Type SweptObjectType
+	// The Openstack ID of the object
+	ID string
+	// The Openstack name of the object
+	Name string
+	// The CBLE deployment (or deployment ID prefix if matched by name) the object belongs to
+	DeploymentID string
+	// The router the interface is attached to (only for router interfaces)
+	RouterID string
+	// Whether the object was deleted
+	Deleted bool
+	// The error encountered deleting the object
+	Error error
+}
+
+type SweepReport struct {
+	// All orphaned objects (in deletion order)
+	Orphans []*SweptObject
+}
+
+// The default name template prefix (first 8 bytes of the deployment ID, followed by the object name)
+var namePrefixRegex = regexp.MustCompile(`^([0-9a-f]{8})-.`)
+
+// Names starting with a full UUID (e.g. objects named after their ID by other services) only look like
+// they have the name template prefix
+var uuidPrefixRegex = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
+// The default minimum age of an object before it is considered an orphan (so objects of deployments
+// which are still deploying, and may not be in the known deployments yet, are skipped)
+const DefaultSweepMinAge = time.Hour
+
+// ReadKnownDeployments reads the IDs of known deployments from a file (one per line). A file without any
+// IDs is an error, as every object would be an orphan
+func ReadKnownDeployments(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open known deployments file: %v", err)
+	}
+	defer f.Close()
+
+	knownDeployments := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// Skip empty lines and comments
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		knownDeployments[line] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read known deployments file: %v", err)
+	}
+	if len(knownDeployments) == 0 {
+		return nil, fmt.Errorf("known deployments file %s contains no deployment IDs", path)
+	}
+	return knownDeployments, nil
+}
+
+// Sweep finds all objects created by this provider which belong to unknown deployments and
+// deletes them (if enabled) in dependency-safe order
+func (provider ProviderOpenstack) Sweep(options SweepOptions) (*SweepReport, error) {
+	// Check if the provider has been configured
+	if CONFIG == nil {
+		return nil, fmt.Errorf("cannot sweep with unconfigured provider, please call Configure()")
+	}
+
+	// Generate authenticated client session
+	authClient, err := provider.newAuthClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to authenticate: %v", err)
+	}
+
+	// Generate the service clients
+	endpointOpts := gophercloud.EndpointOpts{
+		Region: CONFIG.RegionName,
+	}
+	computeClient, err := openstack.NewComputeV2(authClient, endpointOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compute client: %v", err)
+	}
+	blockStorageClient, err := openstack.NewBlockStorageV3(authClient, endpointOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create block storage client: %v", err)
+	}
+	networkClient, err := openstack.NewNetworkV2(authClient, gophercloud.EndpointOpts{
+		Name:   "neutron",
+		Region: CONFIG.RegionName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create openstack network client: %v", err)
+	}
+
+	sweeper := &sweeper{
+		options:            options,
+		computeClient:      computeClient,
+		blockStorageClient: blockStorageClient,
+		networkClient:      networkClient,
+		report:             &SweepReport{},
+	}
+
+	// Find all orphans (in deletion order)
+	if err := sweeper.findServers(); err != nil {
+		return nil, fmt.Errorf("failed to find orphaned servers: %v", err)
+	}
+	if err := sweeper.findVolumes(); err != nil {
+		return nil, fmt.Errorf("failed to find orphaned volumes: %v", err)
+	}
+	if err := sweeper.findPorts(); err != nil {
+		return nil, fmt.Errorf("failed to find orphaned ports: %v", err)
+	}
+	if err := sweeper.findRouters(); err != nil {
+		return nil, fmt.Errorf("failed to find orphaned routers: %v", err)
+	}
+	if err := sweeper.findSubnets(); err != nil {
+		return nil, fmt.Errorf("failed to find orphaned subnets: %v", err)
+	}
+	if err := sweeper.findNetworks(); err != nil {
+		return nil, fmt.Errorf("failed to find orphaned networks: %v", err)
+	}
+
+	// Delete the orphans (if enabled)
+	if options.Delete {
+		sweeper.deleteOrphans()
+	}
+
+	return sweeper.report, nil
+}
+
+type sweeper struct {
+	options            SweepOptions
+	computeClient      *gophercloud.ServiceClient
+	blockStorageClient *gophercloud.ServiceClient
+	networkClient      *gophercloud.ServiceClient
+	report             *SweepReport
+}
+
+// orphanDeployment returns the deployment ID of an object and whether the object is an orphan
+func (s *sweeper) orphanDeployment(tags []string, metadata map[string]string, name string, createdAt time.Time) (string, bool) {
+	// Skip objects which may still be deploying
+	if !createdAt.IsZero() && time.Since(createdAt) < s.options.MinAge {
+		return "", false
+	}
+
+	// Check the tags/metadata for the deployment ID
+	deploymentId := metadata[metadataDeploymentKey]
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, tagDeploymentPrefix) {
+			deploymentId = strings.TrimPrefix(tag, tagDeploymentPrefix)
+			break
+		}
+	}
+	if deploymentId != "" {
+		return deploymentId, !s.options.KnownDeployments[deploymentId]
+	}
+
+	// Fall back to the name prefix if enabled (only objects named by the default name template have it)
+	if s.options.MatchNamePrefix && usesDefaultNameTemplate() && !uuidPrefixRegex.MatchString(name) {
+		if match := namePrefixRegex.FindStringSubmatch(name); match != nil {
+			for knownDeploymentId := range s.options.KnownDeployments {
+				if strings.HasPrefix(knownDeploymentId, match[1]) {
+					return "", false
+				}
+			}
+			return match[1], true
+		}
+	}
+
+	return "", false
+}
+
+// usesDefaultNameTemplate checks if objects are named by the default name template (so the name prefix is
+// the deployment ID prefix)
+func usesDefaultNameTemplate() bool {
+	return CONFIG == nil || CONFIG.NameTemplate == "" || CONFIG.NameTemplate == defaultNameTemplate
+}
+
+func (s *sweeper) addOrphan(orphan *SweptObject) {
+	logrus.Debugf("Found orphaned %s %s (%s) from deployment %s", orphan.Type, orphan.Name, orphan.ID, orphan.DeploymentID)
+	s.report.Orphans = append(s.report.Orphans, orphan)
+}
+
+func (s *sweeper) findServers() error {
+	return servers.List(s.computeClient, servers.ListOpts{}).EachPage(func(p pagination.Page) (bool, error) {
+		ss, err := servers.ExtractServers(p)
+		if err != nil {
+			return false, fmt.Errorf("failed to extract server pages")
+		}
+		for _, server := range ss {
+			if deploymentId, orphaned := s.orphanDeployment(nil, server.Metadata, server.Name, server.Created); orphaned {
+				s.addOrphan(&SweptObject{Type: SweptObjectTypeServer, ID: server.ID, Name: server.Name, DeploymentID: deploymentId})
+			}
+		}
+		return true, nil
+	})
+}
+
+func (s *sweeper) findVolumes() error {
+	return volumes.List(s.blockStorageClient, volumes.ListOpts{}).EachPage(func(p pagination.Page) (bool, error) {
+		vv, err := volumes.ExtractVolumes(p)
+		if err != nil {
+			return false, fmt.Errorf("failed to extract volume pages")
+		}
+		for _, volume := range vv {
+			if deploymentId, orphaned := s.orphanDeployment(nil, volume.Metadata, volume.Name, volume.CreatedAt); orphaned {
+				s.addOrphan(&SweptObject{Type: SweptObjectTypeVolume, ID: volume.ID, Name: volume.Name, DeploymentID: deploymentId})
+			}
+		}
+		return true, nil
+	})
+}
+
+func (s *sweeper) findPorts() error {
+	listOpts := ports.ListOpts{}
+	if !s.options.MatchNamePrefix {
+		listOpts.Tags = tagProvider
+	}
+	return ports.List(s.networkClient, listOpts).EachPage(func(p pagination.Page) (bool, error) {
+		pp, err := ports.ExtractPorts(p)
+		if err != nil {
+			return false, fmt.Errorf("failed to extract port pages")
+		}
+		for _, port := range pp {
+			deploymentId, orphaned := s.orphanDeployment(port.Tags, nil, port.Name, port.CreatedAt)
+			if !orphaned {
+				continue
+			}
+			switch {
+			case isRouterInterfacePort(port.DeviceOwner):
+				// Router interfaces must be removed through the router
+				s.addOrphan(&SweptObject{Type: SweptObjectTypeRouterInterface, ID: port.ID, Name: port.Name, DeploymentID: deploymentId, RouterID: port.DeviceID})
+			case strings.HasPrefix(port.DeviceOwner, "network:"):
+				// Other network owned ports (DHCP, gateways) are removed along with their owner
+			default:
+				s.addOrphan(&SweptObject{Type: SweptObjectTypePort, ID: port.ID, Name: port.Name, DeploymentID: deploymentId})
+			}
+		}
+		return true, nil
+	})
+}
+
+// routerWithCreatedAt is a router with its creation time (not extracted by gophercloud)
+type routerWithCreatedAt struct {
+	routers.Router
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (s *sweeper) findRouters() error {
+	listOpts := routers.ListOpts{}
+	if !s.options.MatchNamePrefix {
+		listOpts.Tags = tagProvider
+	}
+	return routers.List(s.networkClient, listOpts).EachPage(func(p pagination.Page) (bool, error) {
+		rr := []routerWithCreatedAt{}
+		if err := p.(routers.RouterPage).Result.ExtractIntoSlicePtr(&rr, "routers"); err != nil {
+			return false, fmt.Errorf("failed to extract router pages")
+		}
+		for _, router := range rr {
+			if deploymentId, orphaned := s.orphanDeployment(router.Tags, nil, router.Name, router.CreatedAt); orphaned {
+				s.addOrphan(&SweptObject{Type: SweptObjectTypeRouter, ID: router.ID, Name: router.Name, DeploymentID: deploymentId})
+			}
+		}
+		return true, nil
+	})
+}
+
+// subnetWithCreatedAt is a subnet with its creation time (not extracted by gophercloud)
+type subnetWithCreatedAt struct {
+	subnets.Subnet
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (s *sweeper) findSubnets() error {
+	listOpts := subnets.ListOpts{}
+	if !s.options.MatchNamePrefix {
+		listOpts.Tags = tagProvider
+	}
+	return subnets.List(s.networkClient, listOpts).EachPage(func(p pagination.Page) (bool, error) {
+		ss := []subnetWithCreatedAt{}
+		if err := p.(subnets.SubnetPage).Result.ExtractIntoSlicePtr(&ss, "subnets"); err != nil {
+			return false, fmt.Errorf("failed to extract subnet pages")
+		}
+		for _, subnet := range ss {
+			if deploymentId, orphaned := s.orphanDeployment(subnet.Tags, nil, subnet.Name, subnet.CreatedAt); orphaned {
+				s.addOrphan(&SweptObject{Type: SweptObjectTypeSubnet, ID: subnet.ID, Name: subnet.Name, DeploymentID: deploymentId})
+			}
+		}
+		return true, nil
+	})
+}
+
+func (s *sweeper) findNetworks() error {
+	listOpts := networks.ListOpts{}
+	if !s.options.MatchNamePrefix {
+		listOpts.Tags = tagProvider
+	}
+	return networks.List(s.networkClient, listOpts).EachPage(func(p pagination.Page) (bool, error) {
+		nn, err := networks.ExtractNetworks(p)
+		if err != nil {
+			return false, fmt.Errorf("failed to extract network pages")
+		}
+		for _, network := range nn {
+			if deploymentId, orphaned := s.orphanDeployment(network.Tags, nil, network.Name, network.CreatedAt); orphaned {
+				s.addOrphan(&SweptObject{Type: SweptObjectTypeNetwork, ID: network.ID, Name: network.Name, DeploymentID: deploymentId})
+			}
+		}
+		return true, nil
+	})
+}
+
+// deleteOrphans deletes all orphans in the report (already in dependency-safe order)
+func (s *sweeper) deleteOrphans() {
+	for _, orphan := range s.report.Orphans {
+		var err error
+		switch orphan.Type {
+		case SweptObjectTypeServer:
+			if err = servers.Delete(s.computeClient, orphan.ID).ExtractErr(); err == nil {
+				// Wait for the server to be fully deleted (releases its volumes and ports)
+				err = waitForDeletion(func() error {
+					_, err := servers.Get(s.computeClient, orphan.ID).Extract()
+					return err
+				})
+			}
+		case SweptObjectTypeVolume:
+			err = volumes.Delete(s.blockStorageClient, orphan.ID, volumes.DeleteOpts{}).ExtractErr()
+		case SweptObjectTypeRouterInterface:
+			_, err = routers.RemoveInterface(s.networkClient, orphan.RouterID, routers.RemoveInterfaceOpts{
+				PortID: orphan.ID,
+			}).Extract()
+		case SweptObjectTypePort:
+			err = ports.Delete(s.networkClient, orphan.ID).ExtractErr()
+		case SweptObjectTypeRouter:
+			err = routers.Delete(s.networkClient, orphan.ID).ExtractErr()
+		case SweptObjectTypeSubnet:
+			err = subnets.Delete(s.networkClient, orphan.ID).ExtractErr()
+		case SweptObjectTypeNetwork:
+			err = networks.Delete(s.networkClient, orphan.ID).ExtractErr()
+		}
+		// Objects already gone (e.g. deleted along with a server) count as deleted
+		if err != nil && !isNotFound(err) {
+			logrus.Warnf("failed to delete orphaned %s %s (%s): %v", orphan.Type, orphan.Name, orphan.ID, err)
+			orphan.Error = err
+			continue
+		}
+		logrus.Debugf("Deleted orphaned %s %s (%s)", orphan.Type, orphan.Name, orphan.ID)
+		orphan.Deleted = true
+	}
+}
+
+// minAge returns the configured minimum age of orphans (or the default if not set)
+func (config *SweeperConfig) minAge() time.Duration {
+	if config.MinAge == 0 {
+		return DefaultSweepMinAge
+	}
+	return config.MinAge
+}
+
+// sweeperStop stops the currently scheduled sweeper (if any)
+var sweeperStop chan struct{}
+
+// sweeperLock guards sweeperStop (the provider can be configured again while running)
+var sweeperLock sync.Mutex
+
+// scheduleSweeper starts the scheduled sweeper (stopping any previously scheduled sweeper)
+func (provider ProviderOpenstack) scheduleSweeper(config *SweeperConfig) {
+	sweeperLock.Lock()
+	defer sweeperLock.Unlock()
+
+	if sweeperStop != nil {
+		close(sweeperStop)
+		sweeperStop = nil
+	}
+	if config == nil || config.Interval <= 0 {
+		return
+	}
+	if config.KnownDeploymentsFile == "" {
+		logrus.Warnf("sweeper interval set without known_deployments_file, scheduled sweeps are disabled")
+		return
+	}
+
+	stop := make(chan struct{})
+	sweeperStop = stop
+	go func() {
+		ticker := time.NewTicker(config.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				knownDeployments, err := ReadKnownDeployments(config.KnownDeploymentsFile)
+				if err != nil {
+					logrus.Errorf("scheduled sweep failed: %v", err)
+					continue
+				}
+				report, err := provider.Sweep(SweepOptions{
+					KnownDeployments: knownDeployments,
+					Delete:           config.Delete,
+					MinAge:           config.minAge(),
+					MatchNamePrefix:  config.MatchNamePrefix,
+				})
+				if err != nil {
+					logrus.Errorf("scheduled sweep failed: %v", err)
+					continue
+				}
+				for _, orphan := range report.Orphans {
+					logrus.Warnf("orphaned %s %s (%s) from deployment %s (deleted: %t)", orphan.Type, orphan.Name, orphan.ID, orphan.DeploymentID, orphan.Deleted)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,139 @@
+package openstack
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOrphanDeployment(t *testing.T) {
+	s := &sweeper{options: SweepOptions{
+		KnownDeployments: map[string]bool{"11111111-0000-0000-0000-000000000000": true},
+		MinAge:           time.Hour,
+		MatchNamePrefix:  true,
+	}}
+	old := time.Now().Add(-2 * time.Hour)
+
+	tests := []struct {
+		name         string
+		tags         []string
+		objectName   string
+		createdAt    time.Time
+		wantId       string
+		wantOrphaned bool
+	}{
+		{
+			name:         "tagged by unknown deployment",
+			tags:         []string{tagProvider, tagDeploymentPrefix + "22222222-0000-0000-0000-000000000000"},
+			createdAt:    old,
+			wantId:       "22222222-0000-0000-0000-000000000000",
+			wantOrphaned: true,
+		},
+		{
+			name:      "tagged by known deployment",
+			tags:      []string{tagProvider, tagDeploymentPrefix + "11111111-0000-0000-0000-000000000000"},
+			createdAt: old,
+			wantId:    "11111111-0000-0000-0000-000000000000",
+		},
+		{
+			name:      "too new",
+			tags:      []string{tagProvider, tagDeploymentPrefix + "22222222-0000-0000-0000-000000000000"},
+			createdAt: time.Now(),
+		},
+		{
+			name:         "name prefix of unknown deployment",
+			objectName:   "22222222-web",
+			createdAt:    old,
+			wantId:       "22222222",
+			wantOrphaned: true,
+		},
+		{
+			name:       "name prefix of known deployment",
+			objectName: "11111111-web",
+			createdAt:  old,
+		},
+		{
+			name:       "named after a uuid",
+			objectName: "33333333-4444-5555-6666-777777777777",
+			createdAt:  old,
+		},
+		{
+			name:       "uuid prefixed name",
+			objectName: "33333333-4444-5555-6666-777777777777-volume",
+			createdAt:  old,
+		},
+		{
+			name:       "prefix only",
+			objectName: "22222222-",
+			createdAt:  old,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, orphaned := s.orphanDeployment(tt.tags, nil, tt.objectName, tt.createdAt)
+			if id != tt.wantId || orphaned != tt.wantOrphaned {
+				t.Errorf("got (%q, %v), want (%q, %v)", id, orphaned, tt.wantId, tt.wantOrphaned)
+			}
+		})
+	}
+}
+
+func TestSweptCreatedAt(t *testing.T) {
+	// Routers and subnets are protected by the minimum age too
+	var router routerWithCreatedAt
+	if err := json.Unmarshal([]byte(`{"id": "router-id", "created_at": "2024-01-02T03:04:05Z"}`), &router); err != nil {
+		t.Fatal(err)
+	}
+	var subnet subnetWithCreatedAt
+	if err := json.Unmarshal([]byte(`{"id": "subnet-id", "created_at": "2024-01-02T03:04:05Z"}`), &subnet); err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if router.ID != "router-id" || !router.CreatedAt.Equal(want) {
+		t.Errorf("got router %s created at %v", router.ID, router.CreatedAt)
+	}
+	if subnet.ID != "subnet-id" || !subnet.CreatedAt.Equal(want) {
+		t.Errorf("got subnet %s created at %v", subnet.ID, subnet.CreatedAt)
+	}
+}
+
+func TestReadKnownDeployments(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "deployments.txt")
+	os.WriteFile(path, []byte("# known deployments\n11111111-0000-0000-0000-000000000000\n\n  22222222-0000-0000-0000-000000000000  \n"), 0644)
+	got, err := ReadKnownDeployments(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]bool{
+		"11111111-0000-0000-0000-000000000000": true,
+		"22222222-0000-0000-0000-000000000000": true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Every object would be an orphan without any known deployments
+	for name, content := range map[string]string{"empty": "", "comments only": "# truncated\n\n"} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0644)
+		if _, err := ReadKnownDeployments(path); err == nil {
+			t.Errorf("%s: expected error for a file without deployment IDs", name)
+		}
+	}
+}
+
+func TestSweeperConfigMinAge(t *testing.T) {
+	// Scheduled sweeps skip new objects like the sweep command
+	if got := (&SweeperConfig{}).minAge(); got != DefaultSweepMinAge {
+		t.Errorf("got %v without min_age, want %v", got, DefaultSweepMinAge)
+	}
+	if got := (&SweeperConfig{MinAge: 10 * time.Minute}).minAge(); got != 10*time.Minute {
+		t.Errorf("got %v, want the configured min_age", got)
+	}
+}
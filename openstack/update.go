@@ -0,0 +1,769 @@
+package openstack
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	pgrpc "github.com/cble-platform/cble-provider-grpc/pkg/provider"
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/attachinterfaces"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/external"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/routers"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/subnets"
+	"github.com/sirupsen/logrus"
+)
+
+// The var the object of a deployed resource is saved in, so redeploying the resource can update it in
+// place instead of creating it again (prefixed like the other vars the provider keeps for itself)
+const deployedObjectVar = "_deployed_object"
+
+// savedDeployedObject returns the object a resource was deployed from (if saved in its vars by a deploy)
+func savedDeployedObject(vars map[string]string) (string, bool) {
+	deployedObject, ok := vars[deployedObjectVar]
+	return deployedObject, ok
+}
+
+// isBookkeepingVar checks if a var is kept by the provider for itself rather than describing Openstack state
+func isBookkeepingVar(k string) bool {
+	return k == deployedObjectVar || k == typeVar
+}
+
+// withoutBookkeepingVars returns a copy of the dependency vars without the vars the provider keeps for
+// itself, so dependents only see the Openstack state of their dependencies
+func withoutBookkeepingVars(dependencyVars map[string]*pgrpc.DependencyVars) map[string]*pgrpc.DependencyVars {
+	if dependencyVars == nil {
+		return nil
+	}
+	stripped := make(map[string]*pgrpc.DependencyVars, len(dependencyVars))
+	for key, dependency := range dependencyVars {
+		if dependency == nil {
+			stripped[key] = nil
+			continue
+		}
+		vars := make(map[string]string, len(dependency.Vars))
+		for k, v := range dependency.Vars {
+			if !isBookkeepingVar(k) {
+				vars[k] = v
+			}
+		}
+		stripped[key] = &pgrpc.DependencyVars{Vars: vars}
+	}
+	return stripped
+}
+
+// ErrReplaceRequired is returned (wrapped with the reason) when a change can't be made to a deployed
+// resource in place, so the resource has to be destroyed and deployed again
+var ErrReplaceRequired = errors.New("change requires replacing the resource")
+
+// replaceRequired wraps ErrReplaceRequired with the reason the resource has to be replaced
+func replaceRequired(format string, a ...any) error {
+	return fmt.Errorf("%w: %s", ErrReplaceRequired, fmt.Sprintf(format, a...))
+}
+
+// updateResource applies the changes between the deployed object (saved in vars) and the new object of
+// an already deployed resource, replacing the resource only if a change can't be made in place
+func (provider *ProviderOpenstack) updateResource(ctx context.Context, authClient *gophercloud.ProviderClient, request *pgrpc.DeployResourceRequest, object *OpenstackObject) (map[string]string, error) {
+	logrus.Debugf("Updating resource \"%s\"", request.Resource.Key)
+
+	// Unmarshal the deployed object (converting superseded versions like the new object)
+	deployedObjectBytes, _ := savedDeployedObject(request.Vars)
+	deployedObject, err := parseObject(request.Resource.Key, []byte(deployedObjectBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal deployed object: %v", err)
+	}
+	if deployedObject.Resource == nil {
+		return nil, fmt.Errorf("deployed object is not a resource")
+	}
+
+	// Update the resource in place if the type is unchanged
+	var updatedVars map[string]string
+	if *deployedObject.Resource != *object.Resource {
+		err = replaceRequired("type changed from \"%s\" to \"%s\"", *deployedObject.Resource, *object.Resource)
+	} else {
+		var handler ResourceHandler
+		handler, err = getResourceHandler(*object.Resource)
+		if err != nil {
+			return nil, err
+		}
+		updatedVars, err = handler.Update(ctx, provider, authClient, request, deployedObject, object, request.Vars, request.DependencyVars)
+	}
+
+	// Otherwise destroy the deployed resource and deploy it again
+	if errors.Is(err, ErrReplaceRequired) {
+		logrus.Debugf("Replacing resource %s: %v", request.Resource.Key, err)
+		return provider.replaceResource(ctx, authClient, request, deployedObject, object)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return updatedVars, nil
+}
+
+// replaceResource destroys the deployed object of a resource and deploys the new object
+func (provider *ProviderOpenstack) replaceResource(ctx context.Context, authClient *gophercloud.ProviderClient, request *pgrpc.DeployResourceRequest, deployedObject *OpenstackObject, object *OpenstackObject) (map[string]string, error) {
+	deployedHandler, err := getResourceHandler(*deployedObject.Resource)
+	if err != nil {
+		return nil, err
+	}
+	handler, err := getResourceHandler(*object.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	// Destroy the deployed resource as it was deployed
+	deployedObjectBytes, _ := savedDeployedObject(request.Vars)
+	destroyRequest := &pgrpc.DestroyResourceRequest{
+		Deployment: request.Deployment,
+		Resource: &pgrpc.Resource{
+			Id:     request.Resource.Id,
+			Key:    request.Resource.Key,
+			Object: []byte(deployedObjectBytes),
+		},
+		Vars: request.Vars,
+	}
+	vars, err := deployedHandler.Destroy(ctx, provider, authClient, destroyRequest, deployedObject, request.Vars)
+	if err != nil {
+		return nil, fmt.Errorf("failed to destroy deployed %s: %v", deployedObject.Resource.ShortName(), err)
+	}
+	delete(vars, deployedObjectVar)
+
+	// Deploy the new object
+	return handler.Deploy(ctx, provider, authClient, request, object, vars, request.DependencyVars)
+}
+
+// hostUpdatePlan is the changes to make to a deployed host
+type hostUpdatePlan struct {
+	// Should the server be renamed
+	Rename bool
+	// Should the server be resized to the new flavor
+	Resize bool
+	// Networks to detach the server from (removed or with a changed address)
+	DetachNetworks []string
+	// Networks to attach the server to (added or with a changed address)
+	AttachNetworks []string
+}
+
+func (p *hostUpdatePlan) empty() bool {
+	return !p.Rename && !p.Resize && len(p.DetachNetworks) == 0 && len(p.AttachNetworks) == 0
+}
+
+// planHostUpdate works out the changes to make to a deployed host (or why it has to be replaced)
+func planHostUpdate(deployed *OpenstackHost, desired *OpenstackHost) (*hostUpdatePlan, error) {
+	// The boot volume, placement and first boot can't be changed
+	if deployed.Image != desired.Image {
+		return nil, replaceRequired("image changed")
+	}
+	if deployed.DiskSize != desired.DiskSize {
+		return nil, replaceRequired("disk_size changed")
+	}
+	if deployed.AvailabilityZone != desired.AvailabilityZone {
+		return nil, replaceRequired("availability_zone changed")
+	}
+	if !bytes.Equal(deployed.UserData, desired.UserData) {
+		return nil, replaceRequired("user_data changed")
+	}
+
+	plan := &hostUpdatePlan{
+		Rename: deployed.Hostname != desired.Hostname || stringValue(deployed.Name) != stringValue(desired.Name),
+		Resize: deployed.Flavor != desired.Flavor,
+	}
+	plan.DetachNetworks, plan.AttachNetworks = planNetworkAttachments(deployed.Networks, desired.Networks)
+	return plan, nil
+}
+
+// networkUpdatePlan is the changes to make to a deployed network
+type networkUpdatePlan struct {
+	// Should the network and subnet be renamed
+	Rename bool
+	// Should the shared setting be changed
+	Shared bool
+	// Should the external setting be changed
+	External bool
+	// Should the subnet gateway, DHCP ranges or DNS servers be changed
+	Subnet bool
+}
+
+func (p *networkUpdatePlan) empty() bool {
+	return !p.Rename && !p.Shared && !p.External && !p.Subnet
+}
+
+// planNetworkUpdate works out the changes to make to a deployed network (or why it has to be replaced)
+func planNetworkUpdate(deployed *OpenstackNetwork, desired *OpenstackNetwork) (*networkUpdatePlan, error) {
+	// The subnet range and physical segment can't be changed
+	if deployed.Subnet.Masked() != desired.Subnet.Masked() {
+		return nil, replaceRequired("subnet changed")
+	}
+	if !reflect.DeepEqual(deployed.Provider, desired.Provider) {
+		return nil, replaceRequired("provider changed")
+	}
+	// Neutron can't go back to its default gateway or DHCP range once they have been set
+	if deployed.Gateway != nil && desired.Gateway == nil {
+		return nil, replaceRequired("gateway removed")
+	}
+	if len(deployed.DHCP) > 0 && len(desired.DHCP) == 0 {
+		return nil, replaceRequired("every dhcp range removed")
+	}
+
+	return &networkUpdatePlan{
+		Rename:   stringValue(deployed.Name) != stringValue(desired.Name),
+		Shared:   boolValue(deployed.Shared) != boolValue(desired.Shared),
+		External: boolValue(deployed.External) != boolValue(desired.External),
+		Subnet: !addrEqual(deployed.Gateway, desired.Gateway) ||
+			!reflect.DeepEqual(deployed.DHCP, desired.DHCP) ||
+			!reflect.DeepEqual(deployed.Resolvers.ForSubnet(deployed.Subnet), desired.Resolvers.ForSubnet(desired.Subnet)),
+	}, nil
+}
+
+// routerUpdatePlan is the changes to make to a deployed router
+type routerUpdatePlan struct {
+	// Should the router name or description be changed
+	Rename bool
+	// Should the external gateway be changed
+	Gateway bool
+	// Should the static routes be cleared and set again (routes must be cleared while interfaces and
+	// gateways their next hops depend on change)
+	Routes bool
+	// Networks to detach the router from (removed or with a changed address)
+	DetachNetworks []string
+	// Networks to attach the router to (added or with a changed address)
+	AttachNetworks []string
+}
+
+func (p *routerUpdatePlan) empty() bool {
+	return !p.Rename && !p.Gateway && !p.Routes && len(p.DetachNetworks) == 0 && len(p.AttachNetworks) == 0
+}
+
+// planRouterUpdate works out the changes to make to a deployed router (or why it has to be replaced)
+func planRouterUpdate(deployed *OpenstackRouter, desired *OpenstackRouter) (*routerUpdatePlan, error) {
+	// Distributed and HA routers can only be converted while the router is down
+	if boolValue(deployed.Distributed) != boolValue(desired.Distributed) {
+		return nil, replaceRequired("distributed changed")
+	}
+	if boolValue(deployed.HA) != boolValue(desired.HA) {
+		return nil, replaceRequired("ha changed")
+	}
+
+	plan := &routerUpdatePlan{
+		Rename: stringValue(deployed.Name) != stringValue(desired.Name) || stringValue(deployed.Description) != stringValue(desired.Description),
+		Gateway: deployed.ExternalNetwork != desired.ExternalNetwork ||
+			!reflect.DeepEqual(deployed.EnableSNAT, desired.EnableSNAT) ||
+			!reflect.DeepEqual(deployed.ExternalFixedIPs, desired.ExternalFixedIPs),
+	}
+	plan.DetachNetworks, plan.AttachNetworks = planNetworkAttachments(deployed.Networks, desired.Networks)
+	plan.Routes = !reflect.DeepEqual(deployed.Routes, desired.Routes) || plan.Gateway || len(plan.DetachNetworks) > 0
+	return plan, nil
+}
+
+// planNetworkAttachments returns the networks (in order) to detach from and attach to, so the deployed
+// attachments match the desired ones. Attachments with a changed address are detached and attached again
+func planNetworkAttachments(deployed map[string]OpenstackNetworkAttachment, desired map[string]OpenstackNetworkAttachment) ([]string, []string) {
+	detach := []string{}
+	attach := []string{}
+	for k, deployedAttachment := range deployed {
+		desiredAttachment, ok := desired[k]
+		if !ok {
+			detach = append(detach, k)
+		} else if !networkAttachmentEqual(deployedAttachment, desiredAttachment) {
+			detach = append(detach, k)
+			attach = append(attach, k)
+		}
+	}
+	for k := range desired {
+		if _, ok := deployed[k]; !ok {
+			attach = append(attach, k)
+		}
+	}
+	sort.Strings(detach)
+	sort.Strings(attach)
+	return detach, attach
+}
+
+// networkAttachmentEqual checks if two attachments request the same address
+func networkAttachmentEqual(a OpenstackNetworkAttachment, b OpenstackNetworkAttachment) bool {
+	if a.DHCP != b.DHCP {
+		return false
+	}
+	// The IP is ignored when using DHCP
+	return a.DHCP || addrEqual(a.IP, b.IP)
+}
+
+func (provider *ProviderOpenstack) updateHost(ctx context.Context, authClient *gophercloud.ProviderClient, request *pgrpc.DeployResourceRequest, deployedObject *OpenstackObject, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	logrus.Debugf("Updating host \"%s\"", request.Resource.Key)
+
+	// Work out what needs to change
+	plan, err := planHostUpdate(deployedObject.Host, object.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize updated vars to old vars
+	updatedVars := make(map[string]string)
+	for k, v := range vars {
+		updatedVars[k] = v
+	}
+	if plan.empty() {
+		return updatedVars, nil
+	}
+
+	// Get the Openstack server ID from vars
+	osServerId, ok := vars["id"]
+	if !ok {
+		return nil, replaceRequired("no ID found for resource")
+	}
+
+	// Generate the Compute V2 client
+	endpointOpts := gophercloud.EndpointOpts{
+		Region: CONFIG.RegionName,
+	}
+	computeClient, err := openstack.NewComputeV2(authClient, endpointOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compute client: %v", err)
+	}
+
+	// Generate the Network V2 client
+	networkClient, err := openstack.NewNetworkV2(authClient, gophercloud.EndpointOpts{
+		Name:   "neutron",
+		Region: CONFIG.RegionName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create openstack network client: %v", err)
+	}
+
+	if plan.Rename {
+		// Use either hostname, key or provided name as instance name
+		instanceName := object.Host.Hostname
+		if instanceName == "" {
+			instanceName = request.Resource.Key
+		}
+		if object.Host.Name != nil {
+			instanceName = *object.Host.Name
+		}
+		// Generate the instance name from the name template
+		instanceName, err = objectName(request.Deployment, request.Resource, instanceName)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = servers.Update(computeClient, osServerId, servers.UpdateOpts{
+			Name: instanceName,
+		}).Extract()
+		if err != nil {
+			return nil, fmt.Errorf("failed to rename server: %v", err)
+		}
+	}
+
+	if plan.Resize {
+		// Flavor may reference a data object by key
+		hostFlavorRef := object.Host.Flavor
+		if flavorId, ok := dependencyVar(dependencyVars, object.Host.Flavor, "id"); ok {
+			hostFlavorRef = flavorId
+		}
+		hostFlavor, err := CATALOG.Flavor(computeClient, hostFlavorRef)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get host flavor \"%s\": %v", object.Host.Flavor, err)
+		}
+
+		// The new reference may still resolve to the flavor the server has
+		if hostFlavor.ID != vars["flavor_id"] {
+			if err = resizeServer(computeClient, osServerId, hostFlavor.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Detach the server from removed networks (Nova deletes the ports it created)
+	for _, k := range plan.DetachNetworks {
+		if osPortId, ok := vars[k+"_port_id"]; ok {
+			err = attachinterfaces.Delete(computeClient, osServerId, osPortId).ExtractErr()
+			if err != nil && !isNotFound(err) {
+				return nil, fmt.Errorf("failed to detach network %s: %v", k, err)
+			}
+			if err = waitForPortDeletion(networkClient, osPortId); err != nil {
+				return nil, fmt.Errorf("failed to detach network %s: %v", k, err)
+			}
+		}
+
+		// Remove the network details from the vars
+		for _, v := range hostNetworkVarKeys {
+			delete(updatedVars, k+"_"+v)
+		}
+	}
+
+	// Attach the server to added networks
+	for _, k := range plan.AttachNetworks {
+		networkAttachment := object.Host.Networks[k]
+
+		networkId, ok := dependencyVar(dependencyVars, k, "id")
+		if !ok {
+			return nil, fmt.Errorf("ID unknown for network \"%s\"", k)
+		}
+		attachOpts := attachinterfaces.CreateOpts{
+			NetworkID: networkId,
+		}
+		if !networkAttachment.DHCP && networkAttachment.IP != nil {
+			attachOpts.FixedIPs = []attachinterfaces.FixedIP{{IPAddress: networkAttachment.IP.String()}}
+		}
+		serverInterface, err := attachinterfaces.Create(computeClient, osServerId, attachOpts).Extract()
+		if err != nil {
+			return nil, fmt.Errorf("failed to attach network %s: %v", k, err)
+		}
+
+		// Tag the host port
+		tagNetworkObject(networkClient, "ports", serverInterface.PortID, objectTags(request.Deployment, request.Resource))
+	}
+
+	// Map the IDs of attached networks to their keys
+	networkKeys := make(map[string]string)
+	for k := range object.Host.Networks {
+		if networkId, ok := dependencyVar(dependencyVars, k, "id"); ok {
+			networkKeys[networkId] = k
+		}
+	}
+
+	// Save the updated server details and addresses into vars
+	finalServer, err := getServerWithExt(computeClient, osServerId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get openstack server: %v", err)
+	}
+	if err = setHostVars(networkClient, finalServer, networkKeys, updatedVars); err != nil {
+		return nil, fmt.Errorf("failed to get host addresses: %v", err)
+	}
+
+	logrus.Debugf("Successfully updated host %s (%s)", request.Resource.Key, osServerId)
+
+	return updatedVars, nil
+}
+
+// resizeServer resizes a server to a flavor, confirming the resize once it's ready
+func resizeServer(computeClient *gophercloud.ServiceClient, serverId string, flavorId string) error {
+	// Resizing keeps the power state of the server
+	server, err := servers.Get(computeClient, serverId).Extract()
+	if err != nil {
+		return fmt.Errorf("failed to get openstack server status: %v", err)
+	}
+	originalStatus := server.Status
+
+	err = servers.Resize(computeClient, serverId, servers.ResizeOpts{
+		FlavorRef: flavorId,
+	}).ExtractErr()
+	if err != nil {
+		return fmt.Errorf("failed to resize server: %v", err)
+	}
+
+	// Confirm the resize (unless Nova is configured to confirm it automatically)
+	status, err := waitForServerStatus(computeClient, serverId, "VERIFY_RESIZE", originalStatus)
+	if err != nil {
+		return fmt.Errorf("failed to resize server: %v", err)
+	}
+	if status == "VERIFY_RESIZE" {
+		if err = servers.ConfirmResize(computeClient, serverId).ExtractErr(); err != nil {
+			return fmt.Errorf("failed to confirm server resize: %v", err)
+		}
+		if _, err = waitForServerStatus(computeClient, serverId, originalStatus); err != nil {
+			return fmt.Errorf("failed to confirm server resize: %v", err)
+		}
+	}
+	return nil
+}
+
+// waitForServerStatus waits (up to 5 minutes) until the server is in one of the statuses and returns the
+// status reached
+func waitForServerStatus(computeClient *gophercloud.ServiceClient, serverId string, statuses ...string) (string, error) {
+	for i := 0; i < 60; i++ {
+		// Get the updated server from Openstack
+		server, err := servers.Get(computeClient, serverId).Extract()
+		if err != nil {
+			return "", fmt.Errorf("failed to get openstack server status: %v", err)
+		}
+		if server.Status == "ERROR" {
+			// Something happened and this failed
+			return "", fmt.Errorf("server in ERROR state")
+		}
+		for _, status := range statuses {
+			if server.Status == status {
+				return status, nil
+			}
+		}
+		// Wait 5 seconds before checking again
+		time.Sleep(5 * time.Second)
+	}
+	return "", fmt.Errorf("timed out waiting for server status %s", strings.Join(statuses, " or "))
+}
+
+func (provider *ProviderOpenstack) updateNetwork(ctx context.Context, authClient *gophercloud.ProviderClient, request *pgrpc.DeployResourceRequest, deployedObject *OpenstackObject, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	logrus.Debugf("Updating network \"%s\"", request.Resource.Key)
+
+	// Work out what needs to change
+	plan, err := planNetworkUpdate(deployedObject.Network, object.Network)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize updated vars to old vars
+	updatedVars := make(map[string]string)
+	for k, v := range vars {
+		updatedVars[k] = v
+	}
+	if plan.empty() {
+		return updatedVars, nil
+	}
+
+	// Get the Openstack network and subnet IDs from vars
+	osNetworkId, ok := vars["id"]
+	if !ok {
+		return nil, replaceRequired("no ID found for resource")
+	}
+	osSubnetId, ok := vars["subnet_id"]
+	if !ok {
+		return nil, replaceRequired("no subnet ID found for resource")
+	}
+
+	// Generate the Network V2 client
+	endpointOpts := gophercloud.EndpointOpts{
+		Name:   "neutron",
+		Region: CONFIG.RegionName,
+	}
+	networkClient, err := openstack.NewNetworkV2(authClient, endpointOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create openstack network client: %v", err)
+	}
+
+	networkName := request.Resource.Key
+	if object.Network.Name != nil {
+		networkName = *object.Network.Name
+	}
+	// Generate the network name from the name template
+	networkName, err = objectName(request.Deployment, request.Resource, networkName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Update the network
+	if plan.Rename || plan.Shared || plan.External {
+		networkOpts := networks.UpdateOpts{}
+		if plan.Rename {
+			networkOpts.Name = &networkName
+		}
+		if plan.Shared {
+			shared := boolValue(object.Network.Shared)
+			networkOpts.Shared = &shared
+		}
+		var networkConfig networks.UpdateOptsBuilder = networkOpts
+		if plan.External {
+			isExternal := boolValue(object.Network.External)
+			networkConfig = external.UpdateOptsExt{
+				UpdateOptsBuilder: networkConfig,
+				External:          &isExternal,
+			}
+		}
+		_, err = networks.Update(networkClient, osNetworkId, networkConfig).Extract()
+		if err != nil {
+			// Shared and external settings are admin-only by default
+			if isForbidden(err) && (plan.Shared || plan.External) {
+				return nil, fmt.Errorf("failed to update network: shared and external settings require admin credentials: %v", err)
+			}
+			return nil, fmt.Errorf("failed to update network: %v", err)
+		}
+	}
+
+	// Update the subnet
+	if plan.Rename || plan.Subnet {
+		subnetOpts := subnets.UpdateOpts{}
+		if plan.Rename {
+			subnetDescription := fmt.Sprintf("%s Subnet for Network \"%s\"", object.Network.Subnet.String(), networkName)
+			subnetOpts.Name = &networkName
+			subnetOpts.Description = &subnetDescription
+		}
+		if plan.Subnet {
+			if object.Network.Gateway != nil {
+				gatewayString := object.Network.Gateway.String()
+				subnetOpts.GatewayIP = &gatewayString
+			}
+			for _, dhcp := range object.Network.DHCP {
+				subnetOpts.AllocationPools = append(subnetOpts.AllocationPools, subnets.AllocationPool{
+					Start: dhcp.Start.String(),
+					End:   dhcp.End.String(),
+				})
+			}
+			dnsServers := []string{}
+			for _, resolverIP := range object.Network.Resolvers.ForSubnet(object.Network.Subnet) {
+				dnsServers = append(dnsServers, resolverIP.String())
+			}
+			subnetOpts.DNSNameservers = &dnsServers
+		}
+		_, err = subnets.Update(networkClient, osSubnetId, subnetOpts).Extract()
+		if err != nil {
+			return nil, fmt.Errorf("failed to update subnet: %v", err)
+		}
+	}
+
+	// Save the updated network and subnet details into vars
+	updatedSubnet, err := subnets.Get(networkClient, osSubnetId).Extract()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subnet: %v", err)
+	}
+	if err = setNetworkVars(networkClient, osNetworkId, updatedSubnet, updatedVars); err != nil {
+		return nil, fmt.Errorf("failed to get network details: %v", err)
+	}
+
+	logrus.Debugf("Successfully updated network %s (%s)", request.Resource.Key, osNetworkId)
+
+	return updatedVars, nil
+}
+
+func (provider *ProviderOpenstack) updateRouter(ctx context.Context, authClient *gophercloud.ProviderClient, request *pgrpc.DeployResourceRequest, deployedObject *OpenstackObject, object *OpenstackObject, vars map[string]string, dependencyVars map[string]*pgrpc.DependencyVars) (map[string]string, error) {
+	logrus.Debugf("Updating router \"%s\"", request.Resource.Key)
+
+	// Work out what needs to change
+	plan, err := planRouterUpdate(deployedObject.Router, object.Router)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize updated vars to old vars
+	updatedVars := make(map[string]string)
+	for k, v := range vars {
+		updatedVars[k] = v
+	}
+	if plan.empty() {
+		return updatedVars, nil
+	}
+
+	// Get the Openstack router ID from vars
+	osRouterId, ok := vars["id"]
+	if !ok {
+		return nil, replaceRequired("no ID found for resource")
+	}
+
+	// Generate the Network V2 client
+	endpointOpts := gophercloud.EndpointOpts{
+		Name:   "neutron",
+		Region: CONFIG.RegionName,
+	}
+	networkClient, err := openstack.NewNetworkV2(authClient, endpointOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create openstack network client: %v", err)
+	}
+
+	if plan.Rename {
+		// Use either key or provided name as router name
+		routerName := request.Resource.Key
+		if object.Router.Name != nil {
+			routerName = *object.Router.Name
+		}
+		// Generate the router name from the name template
+		routerName, err = objectName(request.Deployment, request.Resource, routerName)
+		if err != nil {
+			return nil, err
+		}
+		routerDescription := stringValue(object.Router.Description)
+
+		_, err = routers.Update(networkClient, osRouterId, routers.UpdateOpts{
+			Name:        routerName,
+			Description: &routerDescription,
+		}).Extract()
+		if err != nil {
+			return nil, fmt.Errorf("failed to rename router: %v", err)
+		}
+	}
+
+	// Remove all routes while interfaces and the gateway change (next hops must stay reachable while routes exist)
+	if plan.Routes {
+		_, err = routers.Update(networkClient, osRouterId, routers.UpdateOpts{
+			Routes: &[]routers.Route{},
+		}).Extract()
+		if err != nil {
+			return nil, fmt.Errorf("failed to clear router routes: %v", err)
+		}
+	}
+
+	// Detach the router from removed networks
+	for _, k := range plan.DetachNetworks {
+		if osPortId, ok := vars[k+"_port_id"]; ok {
+			if err = detachRouterNetwork(networkClient, osRouterId, osPortId, true); err != nil {
+				return nil, err
+			}
+		}
+
+		// Remove router port ID and address from the vars
+		delete(updatedVars, k+"_port_id")
+		delete(updatedVars, k+"_ip")
+	}
+
+	// Update the external gateway (clearing it for internal-only routers)
+	if plan.Gateway {
+		gatewayInfo, err := routerGatewayInfo(networkClient, object.Router, dependencyVars)
+		if err != nil {
+			return nil, err
+		}
+		if gatewayInfo == nil {
+			gatewayInfo = &routers.GatewayInfo{}
+		}
+		_, err = routers.Update(networkClient, osRouterId, routers.UpdateOpts{
+			GatewayInfo: gatewayInfo,
+		}).Extract()
+		if err != nil {
+			// SNAT and fixed IP settings are admin-only by default
+			if isForbidden(err) && (object.Router.EnableSNAT != nil || len(object.Router.ExternalFixedIPs) > 0) {
+				return nil, fmt.Errorf("failed to update router gateway: enable_snat and external_fixed_ips settings may require admin credentials: %v", err)
+			}
+			return nil, fmt.Errorf("failed to update router gateway: %v", err)
+		}
+	}
+
+	// Attach the router to added networks
+	for _, k := range plan.AttachNetworks {
+		portId, err := attachRouterNetwork(networkClient, osRouterId, k, object.Router.Networks[k], dependencyVars)
+		if err != nil {
+			return nil, err
+		}
+
+		// Save the deployed router network port into vars
+		updatedVars[k+"_port_id"] = portId
+
+		// Tag the router port
+		tagNetworkObject(networkClient, "ports", portId, objectTags(request.Deployment, request.Resource))
+	}
+
+	// Add the static routes again once all interfaces are attached
+	if plan.Routes && len(object.Router.Routes) > 0 {
+		routes := routerRoutes(object.Router)
+		_, err = routers.Update(networkClient, osRouterId, routers.UpdateOpts{
+			Routes: &routes,
+		}).Extract()
+		if err != nil {
+			return nil, fmt.Errorf("failed to add router routes: %v", err)
+		}
+	}
+
+	// Map the IDs of attached networks to their keys
+	networkKeys := make(map[string]string)
+	for k := range object.Router.Networks {
+		if networkId, ok := dependencyVar(dependencyVars, k, "id"); ok {
+			networkKeys[networkId] = k
+		}
+	}
+
+	// Save the updated router details and interface addresses into vars (dropping removed routes)
+	for k := range updatedVars {
+		if strings.HasPrefix(k, "route_") {
+			delete(updatedVars, k)
+		}
+	}
+	if err = setRouterVars(networkClient, osRouterId, networkKeys, updatedVars); err != nil {
+		return nil, fmt.Errorf("failed to get router details: %v", err)
+	}
+
+	logrus.Debugf("Successfully updated router %s (%s)", request.Resource.Key, osRouterId)
+
+	return updatedVars, nil
+}
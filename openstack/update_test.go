@@ -0,0 +1,300 @@
+package openstack
+
+import (
+	"errors"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	pgrpc "github.com/cble-platform/cble-provider-grpc/pkg/provider"
+)
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestPlanHostUpdate(t *testing.T) {
+	deployed := OpenstackHost{
+		Hostname:         "host1",
+		Image:            "ubuntu",
+		Flavor:           "small",
+		AvailabilityZone: "nova",
+		DiskSize:         20,
+		Networks: map[string]OpenstackNetworkAttachment{
+			"net1": {DHCP: true},
+			"net2": {IP: addrPtr("10.0.2.10")},
+		},
+		UserData: []byte("#cloud-config"),
+	}
+
+	tests := []struct {
+		name    string
+		change  func(h *OpenstackHost)
+		want    *hostUpdatePlan
+		replace bool
+	}{
+		{
+			name:   "unchanged",
+			change: func(h *OpenstackHost) {},
+			want:   &hostUpdatePlan{DetachNetworks: []string{}, AttachNetworks: []string{}},
+		},
+		{
+			name:   "rename",
+			change: func(h *OpenstackHost) { h.Name = strPtr("Host 1") },
+			want:   &hostUpdatePlan{Rename: true, DetachNetworks: []string{}, AttachNetworks: []string{}},
+		},
+		{
+			name:   "resize",
+			change: func(h *OpenstackHost) { h.Flavor = "large" },
+			want:   &hostUpdatePlan{Resize: true, DetachNetworks: []string{}, AttachNetworks: []string{}},
+		},
+		{
+			name: "networks",
+			change: func(h *OpenstackHost) {
+				h.Networks = map[string]OpenstackNetworkAttachment{
+					"net2": {IP: addrPtr("10.0.2.11")},
+					"net3": {DHCP: true},
+				}
+			},
+			want: &hostUpdatePlan{DetachNetworks: []string{"net1", "net2"}, AttachNetworks: []string{"net2", "net3"}},
+		},
+		{name: "image", change: func(h *OpenstackHost) { h.Image = "debian" }, replace: true},
+		{name: "disk size", change: func(h *OpenstackHost) { h.DiskSize = 40 }, replace: true},
+		{name: "availability zone", change: func(h *OpenstackHost) { h.AvailabilityZone = "az2" }, replace: true},
+		{name: "user data", change: func(h *OpenstackHost) { h.UserData = []byte("#!/bin/sh") }, replace: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			desired := deployed
+			tt.change(&desired)
+			got, err := planHostUpdate(&deployed, &desired)
+			checkPlan(t, got, err, tt.want, tt.replace)
+		})
+	}
+}
+
+func TestPlanNetworkUpdate(t *testing.T) {
+	deployed := OpenstackNetwork{
+		Subnet:  netip.MustParsePrefix("10.0.0.0/24"),
+		Gateway: addrPtr("10.0.0.1"),
+		DHCP: []OpenstackNetworkDHCP{
+			{Start: netip.MustParseAddr("10.0.0.100"), End: netip.MustParseAddr("10.0.0.200")},
+		},
+		Resolvers: OpenstackNetworkResolvers{All: []netip.Addr{netip.MustParseAddr("1.1.1.1")}},
+	}
+
+	tests := []struct {
+		name    string
+		change  func(n *OpenstackNetwork)
+		want    *networkUpdatePlan
+		replace bool
+	}{
+		{name: "unchanged", change: func(n *OpenstackNetwork) {}, want: &networkUpdatePlan{}},
+		{name: "rename", change: func(n *OpenstackNetwork) { n.Name = strPtr("lab") }, want: &networkUpdatePlan{Rename: true}},
+		{name: "shared", change: func(n *OpenstackNetwork) { n.Shared = boolPtr(true) }, want: &networkUpdatePlan{Shared: true}},
+		{name: "shared false is the default", change: func(n *OpenstackNetwork) { n.Shared = boolPtr(false) }, want: &networkUpdatePlan{}},
+		{name: "external", change: func(n *OpenstackNetwork) { n.External = boolPtr(true) }, want: &networkUpdatePlan{External: true}},
+		{name: "gateway", change: func(n *OpenstackNetwork) { n.Gateway = addrPtr("10.0.0.254") }, want: &networkUpdatePlan{Subnet: true}},
+		{
+			name: "dhcp",
+			change: func(n *OpenstackNetwork) {
+				n.DHCP = []OpenstackNetworkDHCP{{Start: netip.MustParseAddr("10.0.0.50"), End: netip.MustParseAddr("10.0.0.60")}}
+			},
+			want: &networkUpdatePlan{Subnet: true},
+		},
+		{
+			name: "resolvers",
+			change: func(n *OpenstackNetwork) {
+				n.Resolvers = OpenstackNetworkResolvers{All: []netip.Addr{netip.MustParseAddr("8.8.8.8")}}
+			},
+			want: &networkUpdatePlan{Subnet: true},
+		},
+		{
+			name: "resolvers of another subnet",
+			change: func(n *OpenstackNetwork) {
+				n.Resolvers.Subnets = map[netip.Prefix][]netip.Addr{
+					netip.MustParsePrefix("fd00::/64"): {netip.MustParseAddr("fd00::53")},
+				}
+			},
+			want: &networkUpdatePlan{},
+		},
+		{name: "subnet", change: func(n *OpenstackNetwork) { n.Subnet = netip.MustParsePrefix("10.0.1.0/24") }, replace: true},
+		{name: "provider", change: func(n *OpenstackNetwork) { n.Provider = &OpenstackNetworkProvider{NetworkType: "vlan"} }, replace: true},
+		{name: "gateway removed", change: func(n *OpenstackNetwork) { n.Gateway = nil }, replace: true},
+		{name: "dhcp removed", change: func(n *OpenstackNetwork) { n.DHCP = nil }, replace: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			desired := deployed
+			tt.change(&desired)
+			got, err := planNetworkUpdate(&deployed, &desired)
+			checkPlan(t, got, err, tt.want, tt.replace)
+		})
+	}
+}
+
+func TestPlanRouterUpdate(t *testing.T) {
+	deployed := OpenstackRouter{
+		ExternalNetwork: "public",
+		Networks: map[string]OpenstackNetworkAttachment{
+			"net1": {IP: addrPtr("10.0.0.1")},
+		},
+		Routes: []OpenstackRouterRoute{
+			{Destination: netip.MustParsePrefix("10.1.0.0/24"), NextHop: netip.MustParseAddr("10.0.0.2")},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		change  func(r *OpenstackRouter)
+		want    *routerUpdatePlan
+		replace bool
+	}{
+		{
+			name:   "unchanged",
+			change: func(r *OpenstackRouter) {},
+			want:   &routerUpdatePlan{DetachNetworks: []string{}, AttachNetworks: []string{}},
+		},
+		{
+			name:   "description",
+			change: func(r *OpenstackRouter) { r.Description = strPtr("edge") },
+			want:   &routerUpdatePlan{Rename: true, DetachNetworks: []string{}, AttachNetworks: []string{}},
+		},
+		{
+			name:   "routes",
+			change: func(r *OpenstackRouter) { r.Routes = nil },
+			want:   &routerUpdatePlan{Routes: true, DetachNetworks: []string{}, AttachNetworks: []string{}},
+		},
+		{
+			// Routes are set again as their next hops may depend on the gateway
+			name:   "gateway",
+			change: func(r *OpenstackRouter) { r.EnableSNAT = boolPtr(false) },
+			want:   &routerUpdatePlan{Gateway: true, Routes: true, DetachNetworks: []string{}, AttachNetworks: []string{}},
+		},
+		{
+			name: "network attached",
+			change: func(r *OpenstackRouter) {
+				r.Networks = map[string]OpenstackNetworkAttachment{"net1": {IP: addrPtr("10.0.0.1")}, "net2": {DHCP: true}}
+			},
+			want: &routerUpdatePlan{DetachNetworks: []string{}, AttachNetworks: []string{"net2"}},
+		},
+		{
+			// Routes are set again as their next hops may depend on the interface
+			name: "network address changed",
+			change: func(r *OpenstackRouter) {
+				r.Networks = map[string]OpenstackNetworkAttachment{"net1": {IP: addrPtr("10.0.0.254")}}
+			},
+			want: &routerUpdatePlan{Routes: true, DetachNetworks: []string{"net1"}, AttachNetworks: []string{"net1"}},
+		},
+		{name: "distributed", change: func(r *OpenstackRouter) { r.Distributed = boolPtr(true) }, replace: true},
+		{name: "ha", change: func(r *OpenstackRouter) { r.HA = boolPtr(true) }, replace: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			desired := deployed
+			tt.change(&desired)
+			got, err := planRouterUpdate(&deployed, &desired)
+			checkPlan(t, got, err, tt.want, tt.replace)
+		})
+	}
+}
+
+func TestPlanNetworkAttachments(t *testing.T) {
+	tests := []struct {
+		name       string
+		deployed   map[string]OpenstackNetworkAttachment
+		desired    map[string]OpenstackNetworkAttachment
+		wantDetach []string
+		wantAttach []string
+	}{
+		{
+			name:       "unchanged",
+			deployed:   map[string]OpenstackNetworkAttachment{"net1": {DHCP: true}},
+			desired:    map[string]OpenstackNetworkAttachment{"net1": {DHCP: true}},
+			wantDetach: []string{},
+			wantAttach: []string{},
+		},
+		{
+			name:       "ip ignored with dhcp",
+			deployed:   map[string]OpenstackNetworkAttachment{"net1": {DHCP: true, IP: addrPtr("10.0.0.10")}},
+			desired:    map[string]OpenstackNetworkAttachment{"net1": {DHCP: true}},
+			wantDetach: []string{},
+			wantAttach: []string{},
+		},
+		{
+			name:       "added and removed",
+			deployed:   map[string]OpenstackNetworkAttachment{"net1": {DHCP: true}},
+			desired:    map[string]OpenstackNetworkAttachment{"net2": {DHCP: true}},
+			wantDetach: []string{"net1"},
+			wantAttach: []string{"net2"},
+		},
+		{
+			// Changed addresses are replaced (detached and attached again)
+			name:       "ip changed",
+			deployed:   map[string]OpenstackNetworkAttachment{"net1": {IP: addrPtr("10.0.0.10")}},
+			desired:    map[string]OpenstackNetworkAttachment{"net1": {IP: addrPtr("10.0.0.11")}},
+			wantDetach: []string{"net1"},
+			wantAttach: []string{"net1"},
+		},
+		{
+			name:       "dhcp to static",
+			deployed:   map[string]OpenstackNetworkAttachment{"net1": {DHCP: true}},
+			desired:    map[string]OpenstackNetworkAttachment{"net1": {IP: addrPtr("10.0.0.10")}},
+			wantDetach: []string{"net1"},
+			wantAttach: []string{"net1"},
+		},
+		{
+			name:       "sorted",
+			deployed:   map[string]OpenstackNetworkAttachment{},
+			desired:    map[string]OpenstackNetworkAttachment{"c": {DHCP: true}, "a": {DHCP: true}, "b": {DHCP: true}},
+			wantDetach: []string{},
+			wantAttach: []string{"a", "b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detach, attach := planNetworkAttachments(tt.deployed, tt.desired)
+			if !reflect.DeepEqual(detach, tt.wantDetach) || !reflect.DeepEqual(attach, tt.wantAttach) {
+				t.Errorf("got detach %v attach %v, want detach %v attach %v", detach, attach, tt.wantDetach, tt.wantAttach)
+			}
+		})
+	}
+}
+
+// checkPlan checks an update plan matches the wanted plan, or that the resource has to be replaced
+func checkPlan[P any](t *testing.T, got *P, err error, want *P, replace bool) {
+	t.Helper()
+	if replace {
+		if !errors.Is(err, ErrReplaceRequired) {
+			t.Fatalf("expected the resource to be replaced, got plan %+v (error %v)", got, err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got plan %+v, want %+v", got, want)
+	}
+}
+
+func TestWithoutBookkeepingVars(t *testing.T) {
+	dependencyVars := map[string]*pgrpc.DependencyVars{
+		"net1": {Vars: map[string]string{
+			"id":              "net-id",
+			deployedObjectVar: "resource: openstack.v1.network",
+			typeVar:           "openstack.v1.network",
+		}},
+	}
+	got := withoutBookkeepingVars(dependencyVars)
+	if want := map[string]string{"id": "net-id"}; !reflect.DeepEqual(got["net1"].Vars, want) {
+		t.Errorf("got %v, want %v", got["net1"].Vars, want)
+	}
+	if len(dependencyVars["net1"].Vars) != 3 {
+		t.Errorf("the request's dependency vars were modified: %v", dependencyVars["net1"].Vars)
+	}
+}
@@ -1,9 +1,117 @@
 package openstack
 
-import "fmt"
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
 
+	pgrpc "github.com/cble-platform/cble-provider-grpc/pkg/provider"
+	"github.com/gophercloud/gophercloud"
+)
+
+// ValidationError is a problem with a single blueprint object
+type ValidationError struct {
+	// The key of the object with the problem
+	Key string
+	// The location of the object in the YAML it was parsed from (if known)
+	Line   int
+	Column int
+	// The problem
+	Err error
+}
+
+func (e ValidationError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s (line %d, column %d): %v", e.Key, e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Key, e.Err)
+}
+
+// ValidationErrors are all the problems found in a blueprint
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	problems := make([]string, len(e))
+	for i, err := range e {
+		problems[i] = err.Error()
+	}
+	return fmt.Sprintf("%d problem(s) found:\n  %s", len(e), strings.Join(problems, "\n  "))
+}
+
+// add records the problems of an object
+func (e *ValidationErrors) add(key string, errs ...error) {
+	for _, err := range errs {
+		*e = append(*e, ValidationError{Key: key, Err: err})
+	}
+}
+
+// locate fills in the location of the object of every problem
+func (e ValidationErrors) locate(blueprint *OpenstackBlueprint) {
+	for i := range e {
+		if o, ok := blueprint.Objects[e[i].Key]; ok {
+			e[i].Line = o.Line
+			e[i].Column = o.Column
+		}
+	}
+}
+
+// blueprintFromResources parses the objects of every resource into a blueprint (as CBLE only sends
+// the individual resource objects to the provider)
+func blueprintFromResources(resources []*pgrpc.Resource) (*OpenstackBlueprint, error) {
+	blueprint := &OpenstackBlueprint{
+		Objects: make(map[string]OpenstackObject),
+	}
+	for _, resource := range resources {
+		object, err := parseObject(resource.Key, resource.Object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse object: %v", err)
+		}
+		blueprint.Objects[resource.Key] = *object
+	}
+	blueprint.populateTypedMaps()
+	return blueprint, nil
+}
+
+// sortedKeys returns the keys of a map in order (so problems are always reported in the same order)
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedPrefixes returns the prefix keys of a map in order
+func sortedPrefixes[V any](m map[netip.Prefix]V) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(m))
+	for p := range m {
+		prefixes = append(prefixes, p)
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		return prefixes[i].String() < prefixes[j].String()
+	})
+	return prefixes
+}
+
+// ValidateBlueprint checks the whole blueprint and returns every problem found as ValidationErrors
+// (or nil if the blueprint is valid)
 func ValidateBlueprint(blueprint *OpenstackBlueprint) error {
-	for k, o := range blueprint.Objects {
+	if errs := validateBlueprint(blueprint); len(errs) > 0 {
+		errs.locate(blueprint)
+		return errs
+	}
+	return nil
+}
+
+// validateBlueprint returns every problem found in the blueprint (not yet located)
+func validateBlueprint(blueprint *OpenstackBlueprint) ValidationErrors {
+	errs := ValidationErrors{}
+
+	for _, k := range sortedKeys(blueprint.Objects) {
+		o := blueprint.Objects[k]
+
 		// Check the object type
 		var t *OpenstackResourceType
 		if o.Resource != nil {
@@ -11,97 +119,473 @@ func ValidateBlueprint(blueprint *OpenstackBlueprint) error {
 		} else if o.Data != nil {
 			t = o.Data
 		} else {
-			return fmt.Errorf("resource or data required")
+			errs.add(k, fmt.Errorf("resource or data required"))
+			continue
 		}
 
-		// Validate individual dependent types
-		switch *t {
-		case OpenstackResourceTypeHost:
-			if err := validateHost(blueprint, k); err != nil {
-				return fmt.Errorf("invalid host \"%s\": %v", k, err)
-			}
-		case OpenstackResourceTypeNetwork:
-			if err := validateNetwork(blueprint, k); err != nil {
-				return fmt.Errorf("invalid network \"%s\": %v", k, err)
-			}
-		case OpenstackResourceTypeRouter:
-			if err := validateRouter(blueprint, k); err != nil {
-				return fmt.Errorf("invalid router \"%s\": %v", k, err)
-			}
+		// Validate the object with the handler of its type
+		handler, err := getResourceHandler(*t)
+		if err != nil {
+			errs.add(k, err)
+			continue
 		}
+		errs.add(k, handler.Validate(blueprint, k)...)
 		// Validate dependencies
 		for _, d := range o.DependsOn {
 			// Check that not self-dependent
 			if d == k {
-				return fmt.Errorf("object \"%s\" dependent on self", k)
+				errs.add(k, fmt.Errorf("dependent on self"))
+				continue
 			}
 			// Check dependency is valid object
 			if _, exists := blueprint.Objects[d]; !exists {
-				return fmt.Errorf("object \"%s\" dependency \"%s\" is undefined", k, d)
+				errs.add(k, fmt.Errorf("dependency \"%s\" is undefined", d))
 			}
 		}
 	}
+
+	// Validate problems across objects
+	errs = append(errs, validateNetworkAddresses(blueprint)...)
+	errs = append(errs, validateDependencyCycles(blueprint)...)
+
+	return errs
+}
+
+// validateBlueprintWithCatalog validates the blueprint and checks its references against the live
+// catalog, returning every problem found together
+func (provider ProviderOpenstack) validateBlueprintWithCatalog(authClient *gophercloud.ProviderClient, blueprint *OpenstackBlueprint) error {
+	errs := validateBlueprint(blueprint)
+	catalogErrs, err := validateCatalog(authClient, blueprint)
+	if err != nil {
+		return fmt.Errorf("failed to validate catalog: %v", err)
+	}
+	errs = append(errs, catalogErrs...)
+	if len(errs) > 0 {
+		errs.locate(blueprint)
+		return errs
+	}
 	return nil
 }
 
-func validateHost(blueprint *OpenstackBlueprint, key string) error {
-	for networkKey, networkAttachment := range blueprint.Hosts[key].Networks {
+// ValidateResources validates the objects of every resource in a deployment, including checking
+// them against the live catalog
+func (provider ProviderOpenstack) ValidateResources(resources []*pgrpc.Resource) error {
+	// Check if the provider has been configured
+	if CONFIG == nil {
+		return fmt.Errorf("cannot validate with unconfigured provider, please call Configure()")
+	}
+
+	// Generate authenticated client session
+	authClient, err := provider.newAuthClient()
+	if err != nil {
+		return fmt.Errorf("failed to authenticate: %v", err)
+	}
+
+	blueprint, err := blueprintFromResources(resources)
+	if err != nil {
+		return err
+	}
+	return provider.validateBlueprintWithCatalog(authClient, blueprint)
+}
+
+func validateHost(blueprint *OpenstackBlueprint, key string) []error {
+	errs := []error{}
+	for _, networkKey := range sortedKeys(blueprint.Hosts[key].Networks) {
+		networkAttachment := blueprint.Hosts[key].Networks[networkKey]
 		// Check that the network key we're attaching to is defined
 		network, exists := blueprint.Networks[networkKey]
 		if !exists {
-			return fmt.Errorf("network object \"%s\" is not defined", networkKey)
+			errs = append(errs, fmt.Errorf("network object \"%s\" is not defined", networkKey))
+			continue
 		}
-		// If not DHCP, check for valid IP address
-		if !networkAttachment.DHCP && networkAttachment.IP != nil {
+		// If not DHCP, check for valid IP address (the subnet of network data is only known once retrieved)
+		if !networkAttachment.DHCP && networkAttachment.IP != nil && network.Subnet.IsValid() {
 			if !network.Subnet.Contains(*networkAttachment.IP) {
-				return fmt.Errorf("ip of %s on network \"%s\" (%s) is not valid", networkAttachment.IP, networkKey, network.Subnet)
+				errs = append(errs, fmt.Errorf("ip of %s on network \"%s\" (%s) is not valid", networkAttachment.IP, networkKey, network.Subnet))
 			}
 		}
 	}
-	return nil
+	return errs
 }
 
-func validateNetwork(blueprint *OpenstackBlueprint, key string) error {
+func validateNetwork(blueprint *OpenstackBlueprint, key string) []error {
+	errs := []error{}
+	network := blueprint.Networks[key]
 	// Check that the gateway address is in the subnet
-	if blueprint.Networks[key].Gateway != nil {
-		if !blueprint.Networks[key].Subnet.Contains(*blueprint.Networks[key].Gateway) {
-			return fmt.Errorf("gateway of %s not in subnet %s", blueprint.Networks[key].Gateway, blueprint.Networks[key].Subnet)
+	if network.Gateway != nil {
+		if !network.Subnet.Contains(*network.Gateway) {
+			errs = append(errs, fmt.Errorf("gateway of %s not in subnet %s", network.Gateway, network.Subnet))
 		}
 	}
 	// If DHCP ranges set, validate them
-	if blueprint.Networks[key].DHCP != nil {
-		for _, dhcp := range blueprint.Networks[key].DHCP {
-			// Check the DHCP ranges are proper in subnet
-			if !blueprint.Networks[key].Subnet.Contains(dhcp.Start) || !blueprint.Networks[key].Subnet.Contains(dhcp.End) {
-				return fmt.Errorf("invalid dhcp range %s - %s: range not in subnet %s", dhcp.Start, dhcp.End, blueprint.Networks[key].Subnet)
-			}
-			rangeCmp := dhcp.Start.Compare(dhcp.End)
-			// Check the start < end
-			if rangeCmp == 0 {
-				return fmt.Errorf("invalid dhcp range %s - %s: must contain at least 2 IP addresses", dhcp.Start, dhcp.End)
-			}
-			// Check the start < end
-			if rangeCmp > 0 {
-				return fmt.Errorf("invalid dhcp range %s - %s: start IP must come before end IP", dhcp.Start, dhcp.End)
-			}
+	gateway, hasGateway := networkGateway(&network)
+	for _, dhcp := range network.DHCP {
+		// Check the DHCP ranges are proper in subnet
+		if !network.Subnet.Contains(dhcp.Start) || !network.Subnet.Contains(dhcp.End) {
+			errs = append(errs, fmt.Errorf("invalid dhcp range %s - %s: range not in subnet %s", dhcp.Start, dhcp.End, network.Subnet))
+			continue
+		}
+		rangeCmp := dhcp.Start.Compare(dhcp.End)
+		// Check the start < end
+		if rangeCmp == 0 {
+			errs = append(errs, fmt.Errorf("invalid dhcp range %s - %s: must contain at least 2 IP addresses", dhcp.Start, dhcp.End))
+			continue
+		}
+		// Check the start < end
+		if rangeCmp > 0 {
+			errs = append(errs, fmt.Errorf("invalid dhcp range %s - %s: start IP must come before end IP", dhcp.Start, dhcp.End))
+			continue
+		}
+		// Check the gateway isn't handed out by DHCP (Neutron rejects this)
+		if hasGateway && dhcpRangeContains(dhcp, gateway) {
+			errs = append(errs, fmt.Errorf("invalid dhcp range %s - %s: contains the gateway %s", dhcp.Start, dhcp.End, gateway))
 		}
 	}
+	// Check the resolvers match the address family of their subnet (and are only set for the network subnet)
+	for _, resolverSubnet := range sortedPrefixes(network.Resolvers.Subnets) {
+		if resolverSubnet.Masked() != network.Subnet.Masked() {
+			errs = append(errs, fmt.Errorf("resolvers set for subnet %s which isn't on the network", resolverSubnet))
+		}
+	}
+	for _, resolver := range network.Resolvers.ForSubnet(network.Subnet) {
+		if network.Subnet.IsValid() && resolver.Is4() != network.Subnet.Addr().Is4() {
+			errs = append(errs, fmt.Errorf("resolver %s is not the same IP version as subnet %s", resolver, network.Subnet))
+		}
+	}
+	// If provider settings set, validate them
+	if network.Provider != nil {
+		if err := validateNetworkProvider(network.Provider); err != nil {
+			errs = append(errs, fmt.Errorf("invalid provider settings: %v", err))
+		}
+	}
+	return errs
+}
+
+func validateNetworkProvider(provider *OpenstackNetworkProvider) error {
+	switch provider.NetworkType {
+	case "flat":
+		// Flat networks are untagged on the physical network
+		if provider.PhysicalNetwork == "" {
+			return fmt.Errorf("physical_network is required for flat networks")
+		}
+		if provider.SegmentationID != nil {
+			return fmt.Errorf("segmentation_id is not allowed for flat networks")
+		}
+	case "vlan":
+		if provider.PhysicalNetwork == "" {
+			return fmt.Errorf("physical_network is required for vlan networks")
+		}
+		if provider.SegmentationID != nil && (*provider.SegmentationID < 1 || *provider.SegmentationID > 4094) {
+			return fmt.Errorf("segmentation_id %d is not a valid VLAN ID (1-4094)", *provider.SegmentationID)
+		}
+	case "vxlan", "geneve", "gre":
+		// Overlay networks aren't bound to a physical network
+		if provider.PhysicalNetwork != "" {
+			return fmt.Errorf("physical_network is not allowed for %s networks", provider.NetworkType)
+		}
+		if provider.SegmentationID != nil && (*provider.SegmentationID < 1 || *provider.SegmentationID > 16777215) {
+			return fmt.Errorf("segmentation_id %d is not a valid %s ID (1-16777215)", *provider.SegmentationID, provider.NetworkType)
+		}
+	case "local":
+		if provider.PhysicalNetwork != "" || provider.SegmentationID != nil {
+			return fmt.Errorf("physical_network and segmentation_id are not allowed for local networks")
+		}
+	case "":
+		return fmt.Errorf("network_type is required")
+	default:
+		return fmt.Errorf("unknown network_type \"%s\"", provider.NetworkType)
+	}
 	return nil
 }
 
-func validateRouter(blueprint *OpenstackBlueprint, key string) error {
-	for networkKey, networkAttachment := range blueprint.Routers[key].Networks {
+func validateRouter(blueprint *OpenstackBlueprint, key string) []error {
+	errs := []error{}
+	router := blueprint.Routers[key]
+	networkKeys := sortedKeys(router.Networks)
+	for _, networkKey := range networkKeys {
+		networkAttachment := router.Networks[networkKey]
 		// Check that the network key we're attaching to is defined
 		network, exists := blueprint.Networks[networkKey]
 		if !exists {
-			return fmt.Errorf("network object \"%s\" is not defined", networkKey)
+			errs = append(errs, fmt.Errorf("network object \"%s\" is not defined", networkKey))
+			continue
 		}
-		// If not DHCP, check for valid IP address
-		if !networkAttachment.DHCP && networkAttachment.IP != nil {
+		// If not DHCP, check for valid IP address (the subnet of network data is only known once retrieved)
+		if !networkAttachment.DHCP && networkAttachment.IP != nil && network.Subnet.IsValid() {
 			if !network.Subnet.Contains(*networkAttachment.IP) {
-				return fmt.Errorf("ip of %s on network \"%s\" (%s) is not valid", networkAttachment.IP, networkKey, network.Subnet)
+				errs = append(errs, fmt.Errorf("ip of %s on network \"%s\" (%s) is not valid", networkAttachment.IP, networkKey, network.Subnet))
 			}
 		}
 	}
-	return nil
+	// Check the attached subnets don't overlap (Neutron can't route between them)
+	for i, networkKey := range networkKeys {
+		network, exists := blueprint.Networks[networkKey]
+		if !exists || !network.Subnet.IsValid() {
+			continue
+		}
+		for _, otherKey := range networkKeys[i+1:] {
+			other, exists := blueprint.Networks[otherKey]
+			if exists && other.Subnet.IsValid() && network.Subnet.Overlaps(other.Subnet) {
+				errs = append(errs, fmt.Errorf("networks \"%s\" (%s) and \"%s\" (%s) have overlapping subnets", networkKey, network.Subnet, otherKey, other.Subnet))
+			}
+		}
+	}
+	// Check the gateway settings are only used with an external network
+	if router.ExternalNetwork == "" && (router.EnableSNAT != nil || len(router.ExternalFixedIPs) > 0) {
+		errs = append(errs, fmt.Errorf("enable_snat and external_fixed_ips require an external network"))
+	}
+	// Check the external fixed IPs have enough info to be requested
+	for _, externalIP := range router.ExternalFixedIPs {
+		if externalIP.IP == nil && externalIP.SubnetID == "" {
+			errs = append(errs, fmt.Errorf("external fixed ip requires either ip or subnet_id"))
+		}
+	}
+	// Check the routes next hops are reachable from an attached network
+	for _, route := range router.Routes {
+		reachable := false
+		for _, networkKey := range networkKeys {
+			if network, exists := blueprint.Networks[networkKey]; exists && (!network.Subnet.IsValid() || network.Subnet.Contains(route.NextHop)) {
+				reachable = true
+				break
+			}
+		}
+		if !reachable {
+			errs = append(errs, fmt.Errorf("route to %s next hop %s is not in any attached network", route.Destination, route.NextHop))
+		}
+	}
+	return errs
+}
+
+// networkGateway returns the gateway IP of a network (Neutron uses the first address of the subnet if not set)
+func networkGateway(network *OpenstackNetwork) (netip.Addr, bool) {
+	if network.Gateway != nil {
+		return *network.Gateway, true
+	}
+	if !network.Subnet.IsValid() {
+		return netip.Addr{}, false
+	}
+	return network.Subnet.Masked().Addr().Next(), true
+}
+
+// dhcpRangeContains checks if an IP is within a DHCP range
+func dhcpRangeContains(dhcp OpenstackNetworkDHCP, ip netip.Addr) bool {
+	return dhcp.Start.Compare(ip) <= 0 && ip.Compare(dhcp.End) <= 0
+}
+
+// validateNetworkAddresses checks the fixed IPs of every host and router don't collide with each other,
+// the network gateway or the network DHCP ranges
+func validateNetworkAddresses(blueprint *OpenstackBlueprint) ValidationErrors {
+	errs := ValidationErrors{}
+
+	// The key of the object using each IP on each network
+	usedIPs := make(map[string]map[netip.Addr]string)
+	claimIP := func(key string, networkKey string, ip netip.Addr) {
+		if usedIPs[networkKey] == nil {
+			usedIPs[networkKey] = make(map[netip.Addr]string)
+		}
+		if otherKey, used := usedIPs[networkKey][ip]; used {
+			errs.add(key, fmt.Errorf("ip %s on network \"%s\" is already used by \"%s\"", ip, networkKey, otherKey))
+			return
+		}
+		usedIPs[networkKey][ip] = key
+	}
+
+	for _, k := range sortedKeys(blueprint.Objects) {
+		o := blueprint.Objects[k]
+		// Only created objects are assigned IPs
+		if o.Resource == nil {
+			continue
+		}
+
+		var attachments map[string]OpenstackNetworkAttachment
+		isRouter := false
+		switch {
+		case o.Host != nil:
+			attachments = o.Host.Networks
+		case o.Router != nil:
+			attachments = o.Router.Networks
+			isRouter = true
+		default:
+			continue
+		}
+
+		for _, networkKey := range sortedKeys(attachments) {
+			attachment := attachments[networkKey]
+			networkObject, exists := blueprint.Objects[networkKey]
+			// Undefined networks are reported by the object validators, and the addresses of network data are unknown
+			if !exists || networkObject.Network == nil || networkObject.Resource == nil {
+				continue
+			}
+			network := networkObject.Network
+			gateway, hasGateway := networkGateway(network)
+
+			if attachment.DHCP {
+				continue
+			}
+			if attachment.IP == nil {
+				// Routers without an IP use the gateway IP
+				if isRouter && hasGateway {
+					claimIP(k, networkKey, gateway)
+				}
+				continue
+			}
+
+			ip := *attachment.IP
+			// Only routers can be the gateway
+			if !isRouter && hasGateway && ip == gateway {
+				errs.add(k, fmt.Errorf("ip %s on network \"%s\" collides with the network gateway", ip, networkKey))
+				continue
+			}
+			// Fixed IPs in DHCP ranges may already be handed out
+			for _, dhcp := range network.DHCP {
+				if dhcpRangeContains(dhcp, ip) {
+					errs.add(k, fmt.Errorf("ip %s on network \"%s\" is inside the dhcp range %s - %s", ip, networkKey, dhcp.Start, dhcp.End))
+					break
+				}
+			}
+			claimIP(k, networkKey, ip)
+		}
+	}
+	return errs
+}
+
+// objectDependencies returns the keys of every object an object depends on (explicitly via depends_on
+// and implicitly via references to other objects)
+func objectDependencies(blueprint *OpenstackBlueprint, key string) []string {
+	o := blueprint.Objects[key]
+	dependencies := append([]string{}, o.DependsOn...)
+	// Implicit dependencies are only added for resources (see ExtractResourceMetadata)
+	if o.Resource == nil {
+		return dependencies
+	}
+	switch {
+	case o.Host != nil:
+		dependencies = append(dependencies, sortedKeys(o.Host.Networks)...)
+		for _, dataKey := range []string{o.Host.Image, o.Host.Flavor, o.Host.AvailabilityZone} {
+			if _, exists := blueprint.Objects[dataKey]; exists {
+				dependencies = append(dependencies, dataKey)
+			}
+		}
+	case o.Router != nil:
+		dependencies = append(dependencies, sortedKeys(o.Router.Networks)...)
+		if _, exists := blueprint.Objects[o.Router.ExternalNetwork]; exists {
+			dependencies = append(dependencies, o.Router.ExternalNetwork)
+		}
+	}
+	return dependencies
+}
+
+// validateDependencyCycles checks there are no cycles in the dependency graph (which could never be deployed)
+func validateDependencyCycles(blueprint *OpenstackBlueprint) ValidationErrors {
+	errs := ValidationErrors{}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	path := []string{}
+
+	var visit func(key string)
+	visit = func(key string) {
+		state[key] = visiting
+		path = append(path, key)
+		for _, d := range objectDependencies(blueprint, key) {
+			// Undefined and self dependencies are reported by ValidateBlueprint
+			if _, exists := blueprint.Objects[d]; !exists || d == key {
+				continue
+			}
+			switch state[d] {
+			case unvisited:
+				visit(d)
+			case visiting:
+				// Found a cycle, report it from where it starts
+				start := 0
+				for i, k := range path {
+					if k == d {
+						start = i
+						break
+					}
+				}
+				cycle := append(append([]string{}, path[start:]...), d)
+				errs.add(d, fmt.Errorf("dependency cycle %s", strings.Join(cycle, " -> ")))
+			}
+		}
+		path = path[:len(path)-1]
+		state[key] = visited
+	}
+
+	for _, k := range sortedKeys(blueprint.Objects) {
+		if state[k] == unvisited {
+			visit(k)
+		}
+	}
+	return errs
+}
+
+func validateImage(blueprint *OpenstackBlueprint, key string) []error {
+	errs := []error{}
+	image := blueprint.Objects[key].Image
+	if image.ID == nil && image.Name == nil && image.NameRegex == nil && len(image.Tags) == 0 && len(image.Properties) == 0 {
+		errs = append(errs, fmt.Errorf("id, name, name_regex, tags or properties required"))
+	}
+	if _, err := compileNameRegex(image.NameRegex); err != nil {
+		errs = append(errs, err)
+	}
+	if _, ok := imageSortKeys[image.SortKey]; image.SortKey != "" && !ok {
+		errs = append(errs, fmt.Errorf("unknown sort_key \"%s\"", image.SortKey))
+	}
+	if _, err := validateSortDir(image.SortDir); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func validateFlavor(blueprint *OpenstackBlueprint, key string) []error {
+	errs := []error{}
+	flavor := blueprint.Objects[key].Flavor
+	if _, err := compileNameRegex(flavor.NameRegex); err != nil {
+		errs = append(errs, err)
+	}
+	if flavor.MaxVCPUs > 0 && flavor.MaxVCPUs < flavor.MinVCPUs {
+		errs = append(errs, fmt.Errorf("max_vcpus is less than min_vcpus"))
+	}
+	if flavor.MaxRAM > 0 && flavor.MaxRAM < flavor.MinRAM {
+		errs = append(errs, fmt.Errorf("max_ram is less than min_ram"))
+	}
+	if flavor.MaxDisk > 0 && flavor.MaxDisk < flavor.MinDisk {
+		errs = append(errs, fmt.Errorf("max_disk is less than min_disk"))
+	}
+	if _, ok := flavorSortKeys[flavor.SortKey]; flavor.SortKey != "" && !ok {
+		errs = append(errs, fmt.Errorf("unknown sort_key \"%s\"", flavor.SortKey))
+	}
+	if _, err := validateSortDir(flavor.SortDir); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func validateAvailabilityZone(blueprint *OpenstackBlueprint, key string) []error {
+	errs := []error{}
+	zone := blueprint.Objects[key].AvailabilityZone
+	if _, err := compileNameRegex(zone.NameRegex); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := validateSortDir(zone.SortDir); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func validateSecurityGroup(blueprint *OpenstackBlueprint, key string) []error {
+	errs := []error{}
+	group := blueprint.Objects[key].SecurityGroup
+	if group.ID == nil && group.Name == nil && group.NameRegex == nil && len(group.Tags) == 0 && group.ProjectID == "" {
+		errs = append(errs, fmt.Errorf("id, name, name_regex, tags or project_id required"))
+	}
+	if _, err := compileNameRegex(group.NameRegex); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
 }
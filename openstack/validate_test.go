@@ -0,0 +1,355 @@
+package openstack
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// networkYAML is a network shared by the validation tests
+const networkYAML = `
+network1:
+  resource: openstack.v1.network
+  config:
+    subnet: 10.0.0.0/24
+    dhcp:
+      - start: 10.0.0.100
+        end: 10.0.0.200
+`
+
+// parseTestBlueprint parses a blueprint for a validation test
+func parseTestBlueprint(t *testing.T, in string) *OpenstackBlueprint {
+	t.Helper()
+	var blueprint OpenstackBlueprint
+	if err := yaml.Unmarshal([]byte(in), &blueprint); err != nil {
+		t.Fatalf("invalid test blueprint: %v", err)
+	}
+	return &blueprint
+}
+
+// checkValidationErrors checks the problems found match the expected object keys and error substrings (in order)
+func checkValidationErrors(t *testing.T, errs []ValidationError, wantKeys []string, wantErrs []string) {
+	t.Helper()
+	if len(errs) != len(wantKeys) {
+		t.Fatalf("got %d problem(s), want %d: %v", len(errs), len(wantKeys), errs)
+	}
+	for i, err := range errs {
+		if err.Key != wantKeys[i] {
+			t.Errorf("problem %d has key %q, want %q", i, err.Key, wantKeys[i])
+		}
+		if !strings.Contains(err.Err.Error(), wantErrs[i]) {
+			t.Errorf("problem %d is %q, want it to contain %q", i, err.Err, wantErrs[i])
+		}
+	}
+}
+
+func TestValidateNetworkAddresses(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		wantKeys []string
+		wantErrs []string
+	}{
+		{
+			name: "distinct ips",
+			in: networkYAML + `
+host1:
+  resource: openstack.v1.host
+  config:
+    networks:
+      network1:
+        ip: 10.0.0.10
+host2:
+  resource: openstack.v1.host
+  config:
+    networks:
+      network1:
+        ip: 10.0.0.11
+host3:
+  resource: openstack.v1.host
+  config:
+    networks:
+      network1:
+        dhcp: true
+router1:
+  resource: openstack.v1.router
+  config:
+    networks:
+      network1: {}
+`,
+		},
+		{
+			name: "hosts share an ip",
+			in: networkYAML + `
+host1:
+  resource: openstack.v1.host
+  config:
+    networks:
+      network1:
+        ip: 10.0.0.10
+host2:
+  resource: openstack.v1.host
+  config:
+    networks:
+      network1:
+        ip: 10.0.0.10
+`,
+			wantKeys: []string{"host2"},
+			wantErrs: []string{"ip 10.0.0.10 on network \"network1\" is already used by \"host1\""},
+		},
+		{
+			name: "host uses the gateway",
+			in: networkYAML + `
+host1:
+  resource: openstack.v1.host
+  config:
+    networks:
+      network1:
+        ip: 10.0.0.1
+`,
+			wantKeys: []string{"host1"},
+			wantErrs: []string{"collides with the network gateway"},
+		},
+		{
+			name: "host uses the ip of a router on the gateway",
+			in: networkYAML + `
+host1:
+  resource: openstack.v1.host
+  config:
+    networks:
+      network1:
+        ip: 10.0.0.1
+router1:
+  resource: openstack.v1.router
+  config:
+    networks:
+      network1: {}
+`,
+			wantKeys: []string{"host1"},
+			wantErrs: []string{"collides with the network gateway"},
+		},
+		{
+			name: "host ip inside the dhcp range",
+			in: networkYAML + `
+host1:
+  resource: openstack.v1.host
+  config:
+    networks:
+      network1:
+        ip: 10.0.0.150
+`,
+			wantKeys: []string{"host1"},
+			wantErrs: []string{"is inside the dhcp range 10.0.0.100 - 10.0.0.200"},
+		},
+		{
+			name: "routers share the gateway",
+			in: networkYAML + `
+router1:
+  resource: openstack.v1.router
+  config:
+    networks:
+      network1: {}
+router2:
+  resource: openstack.v1.router
+  config:
+    networks:
+      network1:
+        ip: 10.0.0.1
+`,
+			wantKeys: []string{"router2"},
+			wantErrs: []string{"is already used by \"router1\""},
+		},
+		{
+			name: "same ip on different networks",
+			in: networkYAML + `
+network2:
+  resource: openstack.v1.network
+  config:
+    subnet: 10.0.1.0/24
+host1:
+  resource: openstack.v1.host
+  config:
+    networks:
+      network1:
+        ip: 10.0.0.10
+host2:
+  resource: openstack.v1.host
+  config:
+    networks:
+      network2:
+        ip: 10.0.0.10
+`,
+		},
+		{
+			name: "addresses on network data are unknown",
+			in: `
+network1:
+  data: openstack.v1.network
+  config:
+    name: lab
+host1:
+  resource: openstack.v1.host
+  config:
+    networks:
+      network1:
+        ip: 10.0.0.10
+host2:
+  resource: openstack.v1.host
+  config:
+    networks:
+      network1:
+        ip: 10.0.0.10
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blueprint := parseTestBlueprint(t, tt.in)
+			checkValidationErrors(t, validateNetworkAddresses(blueprint), tt.wantKeys, tt.wantErrs)
+		})
+	}
+}
+
+func TestValidateRouterOverlappingSubnets(t *testing.T) {
+	tests := []struct {
+		name    string
+		subnet2 string
+		wantErr string
+	}{
+		{
+			name:    "distinct subnets",
+			subnet2: "10.0.1.0/24",
+		},
+		{
+			name:    "same subnet",
+			subnet2: "10.0.0.0/24",
+			wantErr: "networks \"network1\" (10.0.0.0/24) and \"network2\" (10.0.0.0/24) have overlapping subnets",
+		},
+		{
+			name:    "subnet inside the other",
+			subnet2: "10.0.0.128/25",
+			wantErr: "have overlapping subnets",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blueprint := parseTestBlueprint(t, networkYAML+`
+network2:
+  resource: openstack.v1.network
+  config:
+    subnet: `+tt.subnet2+`
+router1:
+  resource: openstack.v1.router
+  config:
+    networks:
+      network1: {}
+      network2: {}
+`)
+			errs := validateRouter(blueprint, "router1")
+			if tt.wantErr == "" {
+				if len(errs) > 0 {
+					t.Fatalf("unexpected problems: %v", errs)
+				}
+				return
+			}
+			if len(errs) != 1 || !strings.Contains(errs[0].Error(), tt.wantErr) {
+				t.Fatalf("got problems %v, want one containing %q", errs, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDependencyCycles(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		wantKeys []string
+		wantErrs []string
+	}{
+		{
+			name: "no cycle",
+			in: networkYAML + `
+host1:
+  resource: openstack.v1.host
+  config:
+    networks:
+      network1:
+        dhcp: true
+host2:
+  resource: openstack.v1.host
+  depends_on:
+    - host1
+  config:
+    networks:
+      network1:
+        dhcp: true
+`,
+		},
+		{
+			name: "explicit cycle",
+			in: networkYAML + `
+host1:
+  resource: openstack.v1.host
+  depends_on:
+    - host2
+  config:
+    networks:
+      network1:
+        dhcp: true
+host2:
+  resource: openstack.v1.host
+  depends_on:
+    - host1
+  config:
+    networks:
+      network1:
+        dhcp: true
+`,
+			wantKeys: []string{"host1"},
+			wantErrs: []string{"dependency cycle host1 -> host2 -> host1"},
+		},
+		{
+			name: "cycle through an implicit network dependency",
+			in: `
+network1:
+  resource: openstack.v1.network
+  depends_on:
+    - host1
+  config:
+    subnet: 10.0.0.0/24
+host1:
+  resource: openstack.v1.host
+  config:
+    networks:
+      network1:
+        dhcp: true
+`,
+			wantKeys: []string{"host1"},
+			wantErrs: []string{"dependency cycle host1 -> network1 -> host1"},
+		},
+		{
+			name: "self and undefined dependencies are not cycles",
+			in: networkYAML + `
+host1:
+  resource: openstack.v1.host
+  depends_on:
+    - host1
+    - missing
+  config:
+    networks:
+      network1:
+        dhcp: true
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blueprint := parseTestBlueprint(t, tt.in)
+			checkValidationErrors(t, validateDependencyCycles(blueprint), tt.wantKeys, tt.wantErrs)
+		})
+	}
+}
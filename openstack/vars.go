@@ -0,0 +1,235 @@
+package openstack
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/availabilityzones"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/routers"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/mtu"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/provider"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/subnets"
+	"github.com/gophercloud/gophercloud/pagination"
+)
+
+// Host vars which aren't specific to a network
+var hostVarKeys = []string{"id", "status", "availability_zone", "access_ipv4", "access_ipv6", "image_id", "flavor_id"}
+
+// Host vars which are set for each network (prefixed with "<network key>_")
+var hostNetworkVarKeys = []string{"ip", "ipv6", "mac", "port_id"}
+
+// Network vars (including subnet details)
+var networkVarKeys = []string{"id", "mtu", "network_type", "physical_network", "segmentation_id", "subnet_id", "cidr", "gateway_ip", "dns_servers"}
+
+// Router vars which aren't specific to a network (routes are saved as "route_<n>_destination" and "route_<n>_nexthop")
+var routerVarKeys = []string{"id", "status", "external_ip", "external_ips", "external_network_id", "enable_snat", "distributed", "ha"}
+
+// serverWithExt is used to extract the server availability zone along with the server
+type serverWithExt struct {
+	servers.Server
+	availabilityzones.ServerAvailabilityZoneExt
+}
+
+// getServerWithExt gets the server with the given ID including extension attributes
+func getServerWithExt(computeClient *gophercloud.ServiceClient, serverId string) (*serverWithExt, error) {
+	var server serverWithExt
+	if err := servers.Get(computeClient, serverId).ExtractInto(&server); err != nil {
+		return nil, err
+	}
+	return &server, nil
+}
+
+// setHostVars saves the server details and the addresses of every server port into vars. Ports are
+// saved under the key of the network they belong to in networkKeys (network ID to key), falling back
+// to the Openstack network name for networks not in networkKeys
+func setHostVars(networkClient *gophercloud.ServiceClient, server *serverWithExt, networkKeys map[string]string, vars map[string]string) error {
+	vars["id"] = server.ID
+	vars["status"] = server.Status
+	vars["availability_zone"] = server.AvailabilityZone
+	vars["access_ipv4"] = server.AccessIPv4
+	vars["access_ipv6"] = server.AccessIPv6
+	// Boot from volume servers have no image
+	if imageId, ok := server.Image["id"].(string); ok {
+		vars["image_id"] = imageId
+	}
+	if flavorId, ok := server.Flavor["id"].(string); ok {
+		vars["flavor_id"] = flavorId
+	}
+
+	// Get all ports attached to the server
+	serverPorts := []ports.Port{}
+	err := ports.List(networkClient, ports.ListOpts{
+		DeviceID: server.ID,
+	}).EachPage(func(p pagination.Page) (bool, error) {
+		pp, err := ports.ExtractPorts(p)
+		if err != nil {
+			return false, fmt.Errorf("failed to extract port pages")
+		}
+		serverPorts = append(serverPorts, pp...)
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list server ports: %v", err)
+	}
+
+	// Track which addresses have been saved (vars may contain stale values)
+	saved := make(map[string]bool)
+	for _, port := range serverPorts {
+		networkKey, ok := networkKeys[port.NetworkID]
+		if !ok {
+			// Fall back to the Openstack network name
+			network, err := networks.Get(networkClient, port.NetworkID).Extract()
+			if err != nil {
+				return fmt.Errorf("failed to get network of port %s: %v", port.ID, err)
+			}
+			networkKey = network.Name
+		}
+
+		vars[networkKey+"_port_id"] = port.ID
+		vars[networkKey+"_mac"] = port.MACAddress
+		for _, fixedIP := range port.FixedIPs {
+			ip, err := netip.ParseAddr(fixedIP.IPAddress)
+			if err != nil {
+				continue
+			}
+			// Only save the first address of each IP version
+			ipKey := networkKey + "_ip"
+			if ip.Is6() {
+				ipKey = networkKey + "_ipv6"
+			}
+			if !saved[ipKey] {
+				vars[ipKey] = ip.String()
+				saved[ipKey] = true
+			}
+		}
+	}
+
+	return nil
+}
+
+// networkWithExt is used to extract the network MTU and provider details along with the network
+type networkWithExt struct {
+	networks.Network
+	mtu.NetworkMTUExt
+	provider.NetworkProviderExt
+}
+
+// setNetworkVars saves the network and subnet details into vars
+func setNetworkVars(networkClient *gophercloud.ServiceClient, networkId string, subnet *subnets.Subnet, vars map[string]string) error {
+	var network networkWithExt
+	if err := networks.Get(networkClient, networkId).ExtractInto(&network); err != nil {
+		return fmt.Errorf("failed to get network: %v", err)
+	}
+
+	vars["id"] = network.ID
+	vars["mtu"] = strconv.Itoa(network.MTU)
+	// Provider details are only visible to admins
+	vars["network_type"] = network.NetworkType
+	vars["physical_network"] = network.PhysicalNetwork
+	vars["segmentation_id"] = network.SegmentationID
+
+	if subnet != nil {
+		vars["subnet_id"] = subnet.ID
+		vars["cidr"] = subnet.CIDR
+		vars["gateway_ip"] = subnet.GatewayIP
+		vars["dns_servers"] = strings.Join(subnet.DNSNameservers, ",")
+	}
+
+	return nil
+}
+
+// routerWithExt is used to extract the L3 HA attribute along with the router
+type routerWithExt struct {
+	routers.Router
+	HA bool `json:"ha"`
+}
+
+// setRouterVars saves the router details and the addresses of every router interface into vars.
+// Interfaces are saved under the key of the network they belong to in networkKeys (network ID to
+// key), falling back to the Openstack network name for networks not in networkKeys
+func setRouterVars(networkClient *gophercloud.ServiceClient, routerId string, networkKeys map[string]string, vars map[string]string) error {
+	var router routerWithExt
+	if err := routers.Get(networkClient, routerId).ExtractIntoStructPtr(&router, "router"); err != nil {
+		return fmt.Errorf("failed to get router: %v", err)
+	}
+
+	vars["id"] = router.ID
+	vars["status"] = router.Status
+
+	// Save the router gateway settings
+	externalIPs := []string{}
+	for _, fixedIP := range router.GatewayInfo.ExternalFixedIPs {
+		externalIPs = append(externalIPs, fixedIP.IPAddress)
+	}
+	if len(externalIPs) > 0 {
+		vars["external_ip"] = externalIPs[0]
+	}
+	vars["external_ips"] = strings.Join(externalIPs, ",")
+	vars["external_network_id"] = router.GatewayInfo.NetworkID
+	if router.GatewayInfo.EnableSNAT != nil {
+		vars["enable_snat"] = strconv.FormatBool(*router.GatewayInfo.EnableSNAT)
+	}
+	vars["distributed"] = strconv.FormatBool(router.Distributed)
+	vars["ha"] = strconv.FormatBool(router.HA)
+	for i, route := range router.Routes {
+		vars[fmt.Sprintf("route_%d_destination", i)] = route.DestinationCIDR
+		vars[fmt.Sprintf("route_%d_nexthop", i)] = route.NextHop
+	}
+
+	// Get all ports attached to the router
+	routerPorts := []ports.Port{}
+	err := ports.List(networkClient, ports.ListOpts{
+		DeviceID: router.ID,
+	}).EachPage(func(p pagination.Page) (bool, error) {
+		pp, err := ports.ExtractPorts(p)
+		if err != nil {
+			return false, fmt.Errorf("failed to extract port pages")
+		}
+		routerPorts = append(routerPorts, pp...)
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list router ports: %v", err)
+	}
+
+	for _, port := range routerPorts {
+		// Skip the gateway and HA network ports
+		if !isRouterInterfacePort(port.DeviceOwner) {
+			continue
+		}
+
+		networkKey, ok := networkKeys[port.NetworkID]
+		if !ok {
+			// Fall back to the Openstack network name
+			network, err := networks.Get(networkClient, port.NetworkID).Extract()
+			if err != nil {
+				return fmt.Errorf("failed to get network of port %s: %v", port.ID, err)
+			}
+			networkKey = network.Name
+		}
+
+		vars[networkKey+"_port_id"] = port.ID
+		if len(port.FixedIPs) > 0 {
+			vars[networkKey+"_ip"] = port.FixedIPs[0].IPAddress
+		}
+	}
+
+	return nil
+}
+
+// isRouterInterfacePort checks if a port device owner is a router interface (including distributed
+// and HA router interfaces)
+func isRouterInterfacePort(deviceOwner string) bool {
+	switch deviceOwner {
+	case "network:router_interface", "network:router_interface_distributed", "network:ha_router_replicated_interface":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,21 @@
+package openstack
+
+import "testing"
+
+func TestIsRouterInterfacePort(t *testing.T) {
+	tests := map[string]bool{
+		"network:router_interface":               true,
+		"network:router_interface_distributed":   true,
+		"network:ha_router_replicated_interface": true,
+		"network:router_gateway":                 false,
+		"network:router_ha_interface":            false,
+		"network:dhcp":                           false,
+		"compute:nova":                           false,
+		"":                                       false,
+	}
+	for deviceOwner, want := range tests {
+		if got := isRouterInterfacePort(deviceOwner); got != want {
+			t.Errorf("isRouterInterfacePort(%q) = %t, want %t", deviceOwner, got, want)
+		}
+	}
+}